@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// auditLog appends one line per notification decision to AUDIT_LOG_PATH:
+//
+//	2006-01-02T15:04:05Z sent slack "<dedup key>"
+//
+// where the decision is sent, failed, queued or suppressed, followed by the
+// backend or the suppression reason. Once the file reaches
+// AUDIT_LOG_MAX_SIZE it is renamed with a ".1" suffix, replacing the
+// previous one, and a new file is started.
+type auditLog struct {
+	path    string
+	maxSize int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+var audit *auditLog
+
+func newAuditLog(path string, maxSize int64) (*auditLog, error) {
+	a := &auditLog{path: path, maxSize: maxSize}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// open opens the file for appending. The caller holds a.mu or has the log
+// to itself.
+func (a *auditLog) open() error {
+	file, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	a.file, a.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the full file aside and starts a new one. The caller holds
+// a.mu.
+func (a *auditLog) rotate() error {
+	a.file.Close()
+	if err := os.Rename(a.path, a.path+".1"); err != nil {
+		return err
+	}
+	return a.open()
+}
+
+func (a *auditLog) record(decision, detail, key string) {
+	line := fmt.Sprintf("%s %s %s %q\n", time.Now().UTC().Format(time.RFC3339), decision, detail, key)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.size > 0 && a.size+int64(len(line)) > a.maxSize {
+		if err := a.rotate(); err != nil {
+			log.Printf("Unable to rotate the audit log: %v", err)
+			if a.open() != nil {
+				return
+			}
+		}
+	}
+	n, err := a.file.WriteString(line)
+	a.size += int64(n)
+	if err != nil {
+		log.Printf("Unable to write to the audit log: %v", err)
+	}
+}
+
+// recordDecision records a decision about the event in the audit log and
+// the notification history, when enabled.
+func recordDecision(event *v1.Event, decision, detail, key string) {
+	if audit != nil {
+		audit.record(decision, detail, key)
+	}
+	if history != nil {
+		history.add(event, decision, detail, key)
+	}
+	recordEvent(event, decision, detail)
+}
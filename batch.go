@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// batchKeyTerms are the terms BATCH_KEY can combine with "+".
+var batchKeyTerms = []string{"namespace", "reason", "kind", "name", "owner"}
+
+// batchMaxLines bounds the per-event lines of a batched message.
+const batchMaxLines = 20
+
+// batcher holds events sharing a BATCH_KEY for BATCH_WINDOW after the first
+// of them and then notifies them together as one message. Batches keyed by
+// owner are consolidated into a message about the owner, e.g. a single
+// message about a Deployment whose rollout fails on all its pods.
+type batcher struct {
+	terms  []string
+	window time.Duration
+
+	mu      sync.Mutex
+	batches map[string]*eventBatch
+}
+
+// eventBatch is a batch waiting for its window to end.
+type eventBatch struct {
+	events []*v1.Event
+	owner  *v1.ObjectReference
+	opened time.Time
+
+	// The clientset of the cluster the events were reported by, to
+	// enrich a batch of one.
+	clientset *kubernetes.Clientset
+}
+
+// newBatcher parses a key expression such as "namespace+reason".
+func newBatcher(key string, window time.Duration) (*batcher, error) {
+	b := &batcher{window: window, batches: map[string]*eventBatch{}}
+	for _, term := range strings.Split(key, "+") {
+		term = strings.TrimSpace(term)
+		if !containsString(batchKeyTerms, term) {
+			return nil, fmt.Errorf("unknown term %q, use %s", term, strings.Join(batchKeyTerms, ", "))
+		}
+		b.terms = append(b.terms, term)
+	}
+	return b, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, entry := range list {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the batch of the event, and the object owning it when the key
+// includes the owner.
+func (b *batcher) key(clientset *kubernetes.Clientset, event *v1.Event) (string, *v1.ObjectReference) {
+	var owner *v1.ObjectReference
+	parts := make([]string, len(b.terms))
+	for i, term := range b.terms {
+		switch term {
+		case "namespace":
+			parts[i] = event.InvolvedObject.Namespace
+		case "reason":
+			parts[i] = canonicalReason(event.Reason)
+		case "kind":
+			parts[i] = event.InvolvedObject.Kind
+		case "name":
+			parts[i] = event.InvolvedObject.Name
+		case "owner":
+			kind, name, err := resolveController(clientset, event.InvolvedObject.Namespace, event.InvolvedObject.Kind, event.InvolvedObject.Name)
+			if err != nil {
+				kind, name = event.InvolvedObject.Kind, event.InvolvedObject.Name
+			}
+			parts[i] = event.InvolvedObject.Namespace + "/" + kind + "/" + name
+			owner = &v1.ObjectReference{Namespace: event.InvolvedObject.Namespace, Kind: kind, Name: name}
+		}
+	}
+	// Objects of different clusters never share a batch.
+	return event.ClusterName + "\x00" + strings.Join(parts, "\x00"), owner
+}
+
+// add queues the event, starting the window if it opens a new batch.
+func (b *batcher) add(clientset *kubernetes.Clientset, event *v1.Event) {
+	key, owner := b.key(clientset, event)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	batch, ok := b.batches[key]
+	if !ok {
+		batch = &eventBatch{owner: owner, opened: time.Now(), clientset: clientset}
+		b.batches[key] = batch
+		time.AfterFunc(b.window, func() { b.flush(key) })
+	}
+	batch.events = append(batch.events, event)
+}
+
+// flush notifies the batch, as is when it holds a single event, through
+// the same routing, outbox and audit as any other notification. The
+// events were recorded as delivered when added, so the batch is claimed
+// under a key of its own.
+func (b *batcher) flush(key string) {
+	b.mu.Lock()
+	batch := b.batches[key]
+	delete(b.batches, key)
+	b.mu.Unlock()
+
+	event, extra := batch.events[0], &enrichment{}
+	if len(batch.events) == 1 {
+		extra = enrichWithin(batch.clientset, event)
+	} else {
+		event = batchEvent(batch.events, batch.owner)
+	}
+	batchKey := fmt.Sprintf("batch\x00%s\x00%d", key, batch.opened.UnixNano())
+	if err := notifier.routed(event).deliver(batchKey, event, extra); err != nil {
+		log.Printf("Unable to notify a batch of %d events: %v", len(batch.events), err)
+	}
+}
+
+// batchEvent builds the synthetic event listing a batch, one line per
+// event. The object, reason and namespace are kept where all events share
+// them, and the event is about the owner of the objects when given.
+func batchEvent(events []*v1.Event, owner *v1.ObjectReference) *v1.Event {
+	first := events[0]
+	batch := &v1.Event{
+		InvolvedObject: first.InvolvedObject,
+		Type:           "Normal",
+		Reason:         first.Reason,
+		FirstTimestamp: first.FirstTimestamp,
+		LastTimestamp:  unversioned.Now(),
+		Count:          int32(len(events)),
+	}
+	batch.ClusterName = first.ClusterName
+	lines := []string{fmt.Sprintf("%d events:", len(events))}
+	for i, event := range events {
+		if event.Type == "Warning" {
+			batch.Type = "Warning"
+		}
+		if event.InvolvedObject.Namespace != batch.InvolvedObject.Namespace {
+			batch.InvolvedObject = v1.ObjectReference{}
+		} else if event.InvolvedObject.Kind != batch.InvolvedObject.Kind || event.InvolvedObject.Name != batch.InvolvedObject.Name {
+			batch.InvolvedObject = v1.ObjectReference{Namespace: event.InvolvedObject.Namespace}
+		}
+		if canonicalReason(event.Reason) != canonicalReason(batch.Reason) {
+			batch.Reason = "Batched"
+		}
+		if i < batchMaxLines {
+			lines = append(lines, fmt.Sprintf("• %s %s: %s: %s", event.InvolvedObject.Kind, event.InvolvedObject.Name, event.Reason, event.Message))
+		}
+	}
+	if len(events) > batchMaxLines {
+		lines = append(lines, fmt.Sprintf("… and %d more", len(events)-batchMaxLines))
+	}
+	if owner != nil {
+		batch.InvolvedObject = *owner
+		lines[0] = fmt.Sprintf("%d events on %d objects of %s %s:", len(events), distinctObjects(events), owner.Kind, owner.Name)
+	}
+	batch.Message = strings.Join(lines, "\n")
+	return batch
+}
+
+// distinctObjects counts the objects the events are about.
+func distinctObjects(events []*v1.Event) int {
+	objects := map[string]bool{}
+	for _, event := range events {
+		objects[event.InvolvedObject.Kind+"/"+event.InvolvedObject.Name] = true
+	}
+	return len(objects)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+func TestBatchFlushQueuesFailures(t *testing.T) {
+	withConfig(t, &Config{SlackFormat: "legacy"})
+	withNotifier(t, multiNotifier{slackNotifier{}})
+	withSlackWebhook(t, http.StatusInternalServerError)
+	o := withOutbox(t)
+
+	b, err := newBatcher("namespace+reason", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.add(nil, testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container"))
+	b.add(nil, testEvent("app", "Pod", "web-2", "BackOff", "Back-off restarting failed container"))
+	for key := range b.batches {
+		b.flush(key)
+	}
+	if queued, _ := o.stats(); queued != 1 {
+		t.Errorf("%d notifications queued after a failed batch, want 1", queued)
+	}
+}
+
+// withObjects serves the objects from the enrichment cache, as if looked
+// up from the API server.
+func withObjects(t *testing.T, objects map[string]interface{}) {
+	saved := objectCache
+	objectCache = newLRUCache("test", time.Hour, 100)
+	for key, object := range objects {
+		objectCache.set(key, object)
+	}
+	t.Cleanup(func() { objectCache = saved })
+}
+
+func controlledBy(kind, name string) []v1.OwnerReference {
+	controller := true
+	return []v1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+}
+
+func TestBatchConsolidatesRolloutFailure(t *testing.T) {
+	withConfig(t, &Config{NormalizeReasons: true})
+	sink := &fakeSink{name: "slack"}
+	withNotifier(t, multiNotifier{sink})
+	withObjects(t, map[string]interface{}{
+		"/Pod/app/web-7d9f-a":         &v1.Pod{ObjectMeta: v1.ObjectMeta{OwnerReferences: controlledBy("ReplicaSet", "web-7d9f")}},
+		"/Pod/app/web-7d9f-b":         &v1.Pod{ObjectMeta: v1.ObjectMeta{OwnerReferences: controlledBy("ReplicaSet", "web-7d9f")}},
+		"/Pod/app/web-7d9f-c":         &v1.Pod{ObjectMeta: v1.ObjectMeta{OwnerReferences: controlledBy("ReplicaSet", "web-7d9f")}},
+		"/ReplicaSet/app/web-7d9f":    &v1beta1.ReplicaSet{ObjectMeta: v1.ObjectMeta{OwnerReferences: controlledBy("Deployment", "web")}},
+		"/Deployment/app/web":         &v1beta1.Deployment{},
+		"/Pod/app/worker-5c4b-a":      &v1.Pod{ObjectMeta: v1.ObjectMeta{OwnerReferences: controlledBy("ReplicaSet", "worker-5c4b")}},
+		"/ReplicaSet/app/worker-5c4b": &v1beta1.ReplicaSet{ObjectMeta: v1.ObjectMeta{OwnerReferences: controlledBy("Deployment", "worker")}},
+		"/Deployment/app/worker":      &v1beta1.Deployment{},
+	})
+
+	b, err := newBatcher("owner+reason", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pod := range []string{"web-7d9f-a", "web-7d9f-b", "web-7d9f-c", "web-7d9f-a"} {
+		b.add(nil, testEvent("app", "Pod", pod, "Failed", "Failed to pull image \"web:broken\""))
+	}
+	b.add(nil, testEvent("app", "Pod", "worker-5c4b-a", "Failed", "Failed to pull image \"worker:broken\""))
+	if len(b.batches) != 2 {
+		t.Fatalf("%d batches, want one per Deployment", len(b.batches))
+	}
+	for key := range b.batches {
+		b.flush(key)
+	}
+
+	if len(sink.events) != 2 {
+		t.Fatalf("%d notifications, want one per Deployment", len(sink.events))
+	}
+	for _, event := range sink.events {
+		if event.InvolvedObject.Name != "web" {
+			continue
+		}
+		if event.InvolvedObject.Kind != "Deployment" || event.Count != 4 || event.Reason != "Failed" {
+			t.Errorf("consolidated notification about %s %s, %d times %s", event.InvolvedObject.Kind, event.InvolvedObject.Name, event.Count, event.Reason)
+		}
+		if !strings.HasPrefix(event.Message, "4 events on 3 objects of Deployment web:") {
+			t.Errorf("consolidated message %q", event.Message)
+		}
+		for _, pod := range []string{"web-7d9f-a", "web-7d9f-b", "web-7d9f-c"} {
+			if !strings.Contains(event.Message, "Pod "+pod+": Failed") {
+				t.Errorf("consolidated message does not list %s: %q", pod, event.Message)
+			}
+		}
+		return
+	}
+	t.Error("no consolidated notification about the web Deployment")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// capacityCacheTTL is how long the cluster capacity is reused, summing it
+// requiring to list every node and running pod.
+const capacityCacheTTL = 30 * time.Second
+
+var capacityCache = newLRUCache("capacity", capacityCacheTTL, 16)
+
+// clusterCapacity holds the allocatable resources of the schedulable nodes
+// and the requests of the pods running on them, cpu in millicores and
+// memory in bytes.
+type clusterCapacity struct {
+	allocatable map[v1.ResourceName]int64
+	requested   map[v1.ResourceName]int64
+}
+
+// capacityResources are the resources summarized, in the order shown.
+var capacityResources = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+
+// schedulingCapacity summarizes how much of the cluster is requested for the
+// resources a FailedScheduling event reports as insufficient, or for all of
+// them when it names none, e.g. "memory 94% requested (60.2Gi of 64.0Gi)".
+func schedulingCapacity(clientset *kubernetes.Clientset, event *v1.Event) ([]string, error) {
+	value, err := capacityCache.fetch(clusterName(clientset), withEnrichmentTimeout(throttled(func() (interface{}, error) {
+		return loadCapacity(clientset)
+	})))
+	if err != nil {
+		return nil, err
+	}
+	capacity := value.(*clusterCapacity)
+
+	resources := []v1.ResourceName{}
+	for _, name := range capacityResources {
+		if strings.Contains(event.Message, "Insufficient "+string(name)) {
+			resources = append(resources, name)
+		}
+	}
+	if len(resources) == 0 {
+		resources = capacityResources
+	}
+
+	var lines []string
+	for _, name := range resources {
+		allocatable := capacity.allocatable[name]
+		if allocatable == 0 {
+			continue
+		}
+		requested := capacity.requested[name]
+		lines = append(lines, fmt.Sprintf("%s %d%% requested (%s of %s)", name, requested*100/allocatable, formatCapacity(name, requested), formatCapacity(name, allocatable)))
+	}
+	return lines, nil
+}
+
+// loadCapacity sums the allocatable resources of the nodes accepting pods
+// and the requests of the pods not yet terminated bound to them.
+func loadCapacity(clientset *kubernetes.Clientset) (*clusterCapacity, error) {
+	nodes, err := clientset.CoreV1().Nodes().List(v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := clientset.CoreV1().Pods(v1.NamespaceAll).List(v1.ListOptions{FieldSelector: "status.phase!=Succeeded,status.phase!=Failed"})
+	if err != nil {
+		return nil, err
+	}
+
+	capacity := &clusterCapacity{allocatable: map[v1.ResourceName]int64{}, requested: map[v1.ResourceName]int64{}}
+	schedulable := map[string]bool{}
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		schedulable[node.Name] = true
+		for _, name := range capacityResources {
+			capacity.allocatable[name] += capacityValue(name, node.Status.Allocatable)
+		}
+	}
+	for _, pod := range pods.Items {
+		if !schedulable[pod.Spec.NodeName] {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			for _, name := range capacityResources {
+				capacity.requested[name] += capacityValue(name, container.Resources.Requests)
+			}
+		}
+	}
+	return capacity, nil
+}
+
+func capacityValue(name v1.ResourceName, resources v1.ResourceList) int64 {
+	quantity, ok := resources[name]
+	if !ok {
+		return 0
+	}
+	if name == v1.ResourceCPU {
+		return quantity.MilliValue()
+	}
+	return quantity.Value()
+}
+
+// formatCapacity shows cpu in cores and memory in GiB.
+func formatCapacity(name v1.ResourceName, value int64) string {
+	if name == v1.ResourceCPU {
+		return fmt.Sprintf("%.1f cores", float64(value)/1000)
+	}
+	return fmt.Sprintf("%.1fGi", float64(value)/(1<<30))
+}
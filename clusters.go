@@ -0,0 +1,124 @@
+package main
+
+import (
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// cluster is a cluster watched by the pod: the one it runs in, or one of
+// the CLUSTER_CONTEXTS watched by the same pod as the others. Its events
+// are tagged with its name, which keeps them apart in deduplication, the
+// enrichment cache and every other per-object state, and is shown in
+// notifications.
+type cluster struct {
+	name string // "" for the cluster the pod runs in
+
+	// The primary cluster also serves the features that are not per
+	// cluster, such as the node watcher and the ConfigMap dedup store.
+	primary bool
+
+	// retired is the clientset the current one replaced, still named for
+	// the lookups in flight with it. Guarded by clusterNamesMu.
+	retired *kubernetes.Clientset
+}
+
+// inCluster is the cluster the pod runs in, watched unless
+// CLUSTER_CONTEXTS is set.
+var inCluster = &cluster{primary: true}
+
+var (
+	clusterNamesMu sync.RWMutex
+	clusterNames   = map[*kubernetes.Clientset]string{}
+	clusterClients = map[string]*kubernetes.Clientset{}
+)
+
+// connectClusters builds a clientset for every context of the kubeconfig
+// found as kubectl does, through KUBECONFIG or ~/.kube/config. The first
+// cluster is the primary one.
+func connectClusters(contexts []string) ([]*cluster, error) {
+	var clusters []*cluster
+	for i, context := range contexts {
+		c := &cluster{name: context, primary: i == 0}
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, nil
+}
+
+// restConfig loads the configuration of the cluster afresh, picking up
+// rotated credentials.
+func (c *cluster) restConfig() (*rest.Config, error) {
+	if c.name == "" {
+		return rest.InClusterConfig()
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{CurrentContext: c.name},
+	).ClientConfig()
+}
+
+// connect (re)builds the clientset of the cluster. The clientset it
+// replaces keeps its name until the next reconnect, as events it delivered
+// may still be in flight, so reconnects don't accumulate clientsets.
+func (c *cluster) connect() error {
+	config, err := c.restConfig()
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	clusterNamesMu.Lock()
+	if c.retired != nil {
+		delete(clusterNames, c.retired)
+	}
+	c.retired = clusterClients[c.name]
+	clusterNames[clientset] = c.name
+	clusterClients[c.name] = clientset
+	clusterNamesMu.Unlock()
+	if c.primary {
+		kubeClientMu.Lock()
+		kubeClient = clientset
+		kubeClientMu.Unlock()
+	}
+	return nil
+}
+
+// clientset is the current clientset of the cluster.
+func (c *cluster) clientset() *kubernetes.Clientset {
+	return clusterClientset(c.name)
+}
+
+// describe names the cluster in log messages.
+func (c *cluster) describe() string {
+	if c.name == "" {
+		return "the cluster"
+	}
+	return "cluster " + c.name
+}
+
+// clusterName is the name of the cluster the clientset talks to, or "" for
+// the in-cluster client.
+func clusterName(clientset *kubernetes.Clientset) string {
+	clusterNamesMu.RLock()
+	defer clusterNamesMu.RUnlock()
+	return clusterNames[clientset]
+}
+
+// clusterClientset is the current clientset of the named cluster, as set
+// on the events of its watch, falling back to the primary cluster's.
+func clusterClientset(name string) *kubernetes.Clientset {
+	clusterNamesMu.RLock()
+	clientset, ok := clusterClients[name]
+	clusterNamesMu.RUnlock()
+	if !ok {
+		return currentClientset()
+	}
+	return clientset
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: east
+  cluster:
+    server: http://127.0.0.1:1
+contexts:
+- name: east
+  context:
+    cluster: east
+current-context: east
+`
+
+func TestReconnectsDoNotAccumulateClientsets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", path)
+	t.Cleanup(func() {
+		clusterNamesMu.Lock()
+		for clientset, name := range clusterNames {
+			if name == "east" {
+				delete(clusterNames, clientset)
+			}
+		}
+		delete(clusterClients, "east")
+		clusterNamesMu.Unlock()
+	})
+
+	c := &cluster{name: "east"}
+	for i := 0; i < 5; i++ {
+		if err := c.connect(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	named := 0
+	clusterNamesMu.RLock()
+	for _, name := range clusterNames {
+		if name == "east" {
+			named++
+		}
+	}
+	clusterNamesMu.RUnlock()
+	if named != 2 {
+		t.Errorf("%d clientsets named after 5 connects, want the current and the retired one", named)
+	}
+	if clusterName(c.retired) != "east" || clusterName(c.clientset()) != "east" {
+		t.Error("the current or retired clientset lost its name")
+	}
+}
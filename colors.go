@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// countColor is a COUNT_COLORS threshold: events repeated at least Count
+// times are shown in Color.
+type countColor struct {
+	Count int32
+	Color string
+}
+
+// parseCountColors parses the count=color pairs of COUNT_COLORS, e.g.
+// "5=#ff9900,20=danger", into thresholds in increasing order.
+func parseCountColors(pairs map[string]string) ([]countColor, error) {
+	var thresholds []countColor
+	for count, color := range pairs {
+		n, err := strconv.ParseInt(count, 10, 32)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid count %q", count)
+		}
+		thresholds = append(thresholds, countColor{Count: int32(n), Color: color})
+	}
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i].Count < thresholds[j].Count })
+	return thresholds, nil
+}
+
+// colorForCount is the color of the highest COUNT_COLORS threshold the
+// count reached, or "" below the first.
+func colorForCount(count int32) string {
+	color := ""
+	for _, threshold := range cfg.CountColors {
+		if count >= threshold.Count {
+			color = threshold.Color
+		}
+	}
+	return color
+}
+
+// colorRank orders Slack attachment colors by severity. Hex colors, only
+// set by COUNT_COLORS, rank between warning and danger.
+func colorRank(color string) int {
+	switch color {
+	case "good":
+		return 0
+	case "warning":
+		return 1
+	case "danger":
+		return 3
+	}
+	return 2
+}
+
+// escalateColor is the color of a Warning event's message given its count:
+// the COUNT_COLORS color if more severe than the color already set. The
+// colors of Normal events and OOM kills are more specific and kept.
+func escalateColor(color string, event *v1.Event) string {
+	if event.Type != "Warning" || color == oomColor {
+		return color
+	}
+	if escalated := colorForCount(event.Count); escalated != "" && colorRank(escalated) > colorRank(color) {
+		return escalated
+	}
+	return color
+}
@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestColorForCount(t *testing.T) {
+	thresholds, err := parseCountColors(map[string]string{"20": "danger", "5": "#ff9900"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, &Config{CountColors: thresholds})
+	for _, test := range []struct {
+		count int32
+		want  string
+	}{
+		{1, ""},
+		{4, ""},
+		{5, "#ff9900"},
+		{6, "#ff9900"},
+		{19, "#ff9900"},
+		{20, "danger"},
+		{21, "danger"},
+	} {
+		if got := colorForCount(test.count); got != test.want {
+			t.Errorf("count %d: got %q, want %q", test.count, got, test.want)
+		}
+	}
+}
+
+func TestColorForCountWithoutThresholds(t *testing.T) {
+	withConfig(t, &Config{})
+	for _, count := range []int32{0, 1, 1000} {
+		if got := colorForCount(count); got != "" {
+			t.Errorf("count %d: got %q without COUNT_COLORS", count, got)
+		}
+	}
+}
+
+func TestParseCountColorsRejectsInvalidCounts(t *testing.T) {
+	for _, count := range []string{"0", "-1", "many"} {
+		if _, err := parseCountColors(map[string]string{count: "danger"}); err == nil {
+			t.Errorf("count %q was accepted", count)
+		}
+	}
+}
+
+func TestCountColorEscalatesOnly(t *testing.T) {
+	thresholds, err := parseCountColors(map[string]string{"1": "warning", "5": "#ff9900", "20": "danger"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, &Config{CountColors: thresholds, FieldOrder: []fieldSpec{{Key: "reason"}}})
+
+	oom := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+	oom.Count = 25
+	message := buildSlackMessage("slack", oom, &enrichment{OOMKilled: &oomKill{Container: "web"}})
+	if got := message.Attachments[0].Color; got != oomColor {
+		t.Errorf("OOM kill repeated %d times shown in %q, want %q", oom.Count, got, oomColor)
+	}
+
+	recovered := normalEvent("app", "Recovered")
+	recovered.Count = 25
+	if got := buildSlackMessage("slack", recovered, &enrichment{}).Attachments[0].Color; got != "good" {
+		t.Errorf("Normal event shown in %q, want good", got)
+	}
+
+	for _, test := range []struct {
+		count int32
+		want  string
+	}{
+		{1, "warning"},
+		{5, "#ff9900"},
+		{20, "danger"},
+	} {
+		event := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+		event.Count = test.count
+		if got := buildSlackMessage("slack", event, &enrichment{}).Attachments[0].Color; got != test.want {
+			t.Errorf("count %d: shown in %q, want %q", test.count, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,584 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the settings read from the environment at startup.
+type Config struct {
+	ExitOnWatchFailure bool
+	WatchHealThreshold int
+	WatchHealInterval  time.Duration
+	WatchResume        bool
+	ReplayMaxGap       time.Duration
+	WatchPingInterval  time.Duration
+	WatchPingFailures  int
+
+	ClusterContexts []string
+
+	EventsAPI        string
+	ShowEventAction  bool
+	NormalizeReasons bool
+	TypeReasonRules  []typeReasonRule
+
+	SuppressSelfEvents bool
+	EmitEvents         string
+
+	IncludeControllerKinds []string
+	JobAware               bool
+
+	SlackFormat   string
+	MessagePrefix string
+	EmptyMessage  string
+	EmptyKind     string
+	ReleaseID     string
+	FieldOrder    []fieldSpec
+	ThumbURLs     map[string]string
+	CountColors   []countColor
+
+	SlackMaxFields       int
+	SlackFieldsMaxLength int
+
+	AuthorLinkMode     string
+	AuthorLinkTemplate string
+
+	ParseMessageFields bool
+
+	NotifyTargets     []string
+	GenericWebhookURL string
+	WebhookGzip       bool
+	WebhookHeaders    map[string]string
+	UnixSocketPath    string
+
+	WebhookFieldMap      map[string]string
+	WebhookExcludeFields []string
+
+	GoogleChatWebhookURL string
+
+	IncidentAPIURL string
+
+	SlackBotToken string
+	SlackChannel  string
+	LogPermalinks bool
+
+	AllowedChannels []string
+
+	SlackThreadByObject  bool
+	SlackThreadTTL       time.Duration
+	SlackThreadBroadcast bool
+
+	MirrorStdout        bool
+	ShowController      bool
+	StartupWarningGrace time.Duration
+	DetectOOM           bool
+
+	ShowPodResources    bool
+	ShowClusterCapacity bool
+
+	SkipTerminatingNamespaces bool
+
+	CriticalReasons     []string
+	BusinessHours       *businessHours
+	OffHoursMinSeverity string
+
+	Priorities   map[string]priority
+	NotifyRules  []routingRule
+	SlackMention string
+
+	WatchNodes     bool
+	NodeConditions []string
+
+	ShowRecentEvents   bool
+	RecentEventsLimit  int
+	RecentEventsWindow time.Duration
+
+	RecoveryCheckInterval time.Duration
+	FlapThreshold         int
+	FlapWindow            time.Duration
+	PrometheusURL         string
+
+	EnrichmentCacheTTL  time.Duration
+	EnrichmentCacheSize int
+	EnrichmentTimeout   time.Duration
+	NotifyTimeout       time.Duration
+	EnrichmentQPS       float64
+
+	DedupTTL           time.Duration
+	DedupIgnoreNumbers bool
+	DedupCountBuckets  []int
+	DedupPerGeneration bool
+	DedupScope         string
+	DedupNodeReasons   []string
+
+	DedupOngoingInterval time.Duration
+	DedupBackoff         []time.Duration
+
+	NormalAfterWarning bool
+	ShowCountDelta     bool
+
+	DedupStore     string
+	DedupConfigMap string
+
+	SeriesMode string
+
+	DigestInterval   time.Duration
+	DigestGroupBy    string
+	DigestNamespaces []string
+	DigestAnnotation string
+
+	BatchKey    string
+	BatchWindow time.Duration
+
+	OutboxDir           string
+	OutboxRetryInterval time.Duration
+	OutboxMaxAge        time.Duration
+	DeadLetterMaxAge    time.Duration
+
+	AuditLogPath    string
+	AuditLogMaxSize int
+
+	HistoryExportPath     string
+	HistoryExportInterval time.Duration
+	HistoryExportMaxSize  int
+	HistoryExportRotate   time.Duration
+	HistoryExportKeep     int
+
+	FailureAlertAfter time.Duration
+	FailureMarkerFile string
+
+	StartupSelfTest   bool
+	GRPCHealthAddr    string
+	SelfTestNamespace string
+
+	SuppressionLog        string
+	SuppressionAlertRate  int
+	SuppressionAlertAfter time.Duration
+
+	StormDetailLimit     int
+	StormSummaryInterval time.Duration
+	StormQuietPeriod     time.Duration
+
+	ThrottleKey   string
+	ThrottleRate  float64
+	ThrottleBurst int
+}
+
+var cfg = &Config{}
+
+// loadConfig parses and validates every setting, reporting all problems at
+// once rather than stopping at the first.
+func loadConfig() (*Config, error) {
+	env := &envParser{}
+	c := &Config{}
+	env.url("OPENSHIFT_CONSOLE_URL")
+	c.ExitOnWatchFailure = env.bool("EXIT_ON_WATCH_FAILURE", false)
+	c.WatchHealThreshold = env.int("WATCH_HEAL_THRESHOLD", 5)
+	c.WatchHealInterval = env.duration("WATCH_HEAL_INTERVAL", 10*time.Minute)
+	c.WatchResume = env.bool("WATCH_RESUME", false)
+	c.ReplayMaxGap = env.duration("REPLAY_MAX_GAP", 5*time.Minute)
+	c.WatchPingInterval = env.duration("WATCH_PING_INTERVAL", 0)
+	c.WatchPingFailures = env.positiveInt("WATCH_PING_FAILURES", 3)
+	c.ClusterContexts = env.list("CLUSTER_CONTEXTS")
+	c.EventsAPI = env.oneOf("EVENTS_API", "core", "events", "auto")
+	c.ShowEventAction = env.bool("SHOW_EVENT_ACTION", false)
+	c.NormalizeReasons = env.bool("NORMALIZE_REASONS", true)
+	c.SuppressSelfEvents = env.bool("SUPPRESS_SELF_EVENTS", false)
+	c.EmitEvents = env.oneOf("EMIT_EVENTS", "off", "failures", "all")
+	c.SlackFormat = env.oneOf("SLACK_FORMAT", formatterNames()...)
+	c.MessagePrefix = os.Getenv("MESSAGE_PREFIX")
+	c.EmptyMessage = os.Getenv("EMPTY_MESSAGE_PLACEHOLDER")
+	if c.EmptyMessage == "" {
+		c.EmptyMessage = "(no message)"
+	}
+	c.EmptyKind = os.Getenv("EMPTY_KIND_PLACEHOLDER")
+	c.ReleaseID = os.Getenv("RELEASE_ID")
+	if specs, err := parseFieldOrder(env.list("FIELD_ORDER", defaultFieldOrder...)); err != nil {
+		env.fail("FIELD_ORDER", os.Getenv("FIELD_ORDER"), err)
+	} else {
+		c.FieldOrder = specs
+	}
+	c.SlackMaxFields = env.int("SLACK_MAX_FIELDS", 0)
+	c.SlackFieldsMaxLength = env.int("SLACK_FIELDS_MAX_LENGTH", 8000)
+	c.ThumbURLs = map[string]string{}
+	for _, severity := range severities {
+		if thumb := env.url("THUMB_URL_" + strings.ToUpper(severity)); thumb != "" {
+			c.ThumbURLs[severity] = thumb
+		}
+	}
+	if colors, err := parseCountColors(env.pairs("COUNT_COLORS")); err != nil {
+		env.fail("COUNT_COLORS", os.Getenv("COUNT_COLORS"), err)
+	} else {
+		c.CountColors = colors
+	}
+	c.AuthorLinkMode = env.oneOf("AUTHOR_LINK_MODE", "monitoring", "overview", "grafana", "none", "custom-template")
+	c.AuthorLinkTemplate = env.url("AUTHOR_LINK_TEMPLATE")
+	if (c.AuthorLinkMode == "grafana" || c.AuthorLinkMode == "custom-template") && c.AuthorLinkTemplate == "" {
+		env.fail("AUTHOR_LINK_TEMPLATE", "", fmt.Errorf("required with AUTHOR_LINK_MODE=%s", c.AuthorLinkMode))
+	}
+	c.ParseMessageFields = env.bool("PARSE_MESSAGE_FIELDS", false)
+	c.NotifyTargets = env.list("NOTIFY_TARGETS", "slack")
+	c.GenericWebhookURL = env.url("GENERIC_WEBHOOK_URL")
+	c.IncidentAPIURL = env.url("INCIDENT_API_URL")
+	if headers := env.secret("WEBHOOK_HEADERS"); headers != "" {
+		var err error
+		if c.WebhookHeaders, err = parseHeaders(headers); err != nil {
+			env.fail("WEBHOOK_HEADERS", "(redacted)", err)
+		}
+	}
+	c.WebhookFieldMap = env.pairs("WEBHOOK_FIELD_MAP")
+	for field := range c.WebhookFieldMap {
+		if !containsString(webhookFields(), field) {
+			env.fail("WEBHOOK_FIELD_MAP", os.Getenv("WEBHOOK_FIELD_MAP"), fmt.Errorf("unknown field %q", field))
+		}
+	}
+	c.WebhookExcludeFields = env.list("WEBHOOK_EXCLUDE_FIELDS")
+	for _, field := range c.WebhookExcludeFields {
+		if !containsString(webhookFields(), field) {
+			env.fail("WEBHOOK_EXCLUDE_FIELDS", os.Getenv("WEBHOOK_EXCLUDE_FIELDS"), fmt.Errorf("unknown field %q", field))
+		}
+	}
+	c.WebhookGzip = env.bool("WEBHOOK_GZIP", false)
+	c.UnixSocketPath = os.Getenv("UNIX_SOCKET_PATH")
+	c.GoogleChatWebhookURL = env.url("GOOGLE_CHAT_WEBHOOK_URL")
+	c.SlackBotToken = env.secret("SLACK_BOT_TOKEN")
+	c.SlackChannel = os.Getenv("SLACK_CHANNEL")
+	c.LogPermalinks = env.bool("LOG_PERMALINKS", false)
+	c.AllowedChannels = env.list("ALLOWED_CHANNELS")
+	c.SlackThreadByObject = env.bool("SLACK_THREAD_BY_OBJECT", false)
+	c.SlackThreadTTL = env.duration("SLACK_THREAD_TTL", 24*time.Hour)
+	c.SlackThreadBroadcast = env.bool("SLACK_THREAD_BROADCAST", false)
+	c.MirrorStdout = env.bool("MIRROR_STDOUT", false)
+	c.ShowController = env.bool("SHOW_CONTROLLER", false)
+	c.StartupWarningGrace = env.duration("STARTUP_WARNING_GRACE", 0)
+	c.DetectOOM = env.bool("DETECT_OOM", false)
+	c.ShowPodResources = env.bool("SHOW_POD_RESOURCES", false)
+	c.ShowClusterCapacity = env.bool("SHOW_CLUSTER_CAPACITY", false)
+	c.SkipTerminatingNamespaces = env.bool("SKIP_TERMINATING_NAMESPACES", false)
+	c.WatchNodes = env.bool("WATCH_NODES", false)
+	c.NodeConditions = env.list("NODE_CONDITIONS", "Ready", "MemoryPressure", "DiskPressure")
+	c.ShowRecentEvents = env.bool("SHOW_RECENT_EVENTS", false)
+	c.RecentEventsLimit = env.int("RECENT_EVENTS_LIMIT", 5)
+	c.RecentEventsWindow = env.duration("RECENT_EVENTS_WINDOW", 15*time.Minute)
+	c.RecoveryCheckInterval = env.duration("RECOVERY_CHECK_INTERVAL", 0)
+	c.FlapThreshold = env.int("FLAP_THRESHOLD", 0)
+	c.FlapWindow = env.duration("FLAP_WINDOW", 10*time.Minute)
+	c.PrometheusURL = env.url("PROMETHEUS_URL")
+	c.EnrichmentCacheTTL = env.duration("ENRICHMENT_CACHE_TTL", 30*time.Second)
+	c.EnrichmentCacheSize = env.positiveInt("ENRICHMENT_CACHE_SIZE", 1000)
+	c.EnrichmentTimeout = env.duration("ENRICHMENT_TIMEOUT", 3*time.Second)
+	c.NotifyTimeout = env.duration("NOTIFY_TIMEOUT", 0)
+	c.EnrichmentQPS = env.float("ENRICHMENT_QPS", 0)
+	c.DedupTTL = env.duration("DEDUP_TTL", 0)
+	c.DedupIgnoreNumbers = env.bool("DEDUP_IGNORE_NUMBERS", false)
+	c.DedupCountBuckets = env.ints("DEDUP_COUNT_BUCKETS")
+	c.DedupPerGeneration = env.bool("DEDUP_PER_GENERATION", false)
+	c.DedupScope = env.oneOf("DEDUP_SCOPE", "namespace", "cluster")
+	c.DedupNodeReasons = env.list("DEDUP_NODE_REASONS")
+	c.DedupOngoingInterval = env.duration("DEDUP_ONGOING_INTERVAL", 0)
+	c.DedupBackoff = env.durations("DEDUP_BACKOFF")
+	if len(c.DedupBackoff) > 0 && c.DedupTTL == 0 {
+		env.fail("DEDUP_BACKOFF", os.Getenv("DEDUP_BACKOFF"), errors.New("requires DEDUP_TTL"))
+	}
+	c.NormalAfterWarning = env.bool("NORMAL_AFTER_WARNING", false)
+	if c.NormalAfterWarning && c.DedupTTL == 0 {
+		env.fail("NORMAL_AFTER_WARNING", "true", errors.New("requires DEDUP_TTL"))
+	}
+	c.ShowCountDelta = env.bool("SHOW_COUNT_DELTA", false)
+	if c.ShowCountDelta && c.DedupTTL == 0 {
+		env.fail("SHOW_COUNT_DELTA", "true", errors.New("requires DEDUP_TTL"))
+	}
+	c.DedupStore = env.oneOf("DEDUP_STORE", "memory", "configmap")
+	c.DedupConfigMap = os.Getenv("DEDUP_CONFIGMAP")
+	if c.DedupConfigMap == "" {
+		c.DedupConfigMap = "openshift-slack-notifications-dedup"
+	}
+	c.SeriesMode = env.oneOf("SERIES_MODE", "off", "suppress", "update", "thread")
+	c.DigestInterval = env.duration("DIGEST_INTERVAL", 0)
+	c.DigestGroupBy = env.oneOf("DIGEST_GROUP_BY", "namespace+reason", "namespace", "reason")
+	c.DigestNamespaces = env.list("DIGEST_NAMESPACES")
+	c.DigestAnnotation = os.Getenv("DIGEST_ANNOTATION")
+	if len(c.DigestNamespaces) > 0 && c.DigestInterval == 0 {
+		env.fail("DIGEST_NAMESPACES", os.Getenv("DIGEST_NAMESPACES"), errors.New("requires DIGEST_INTERVAL"))
+	}
+	if c.DigestAnnotation != "" && c.DigestInterval == 0 {
+		env.fail("DIGEST_ANNOTATION", c.DigestAnnotation, errors.New("requires DIGEST_INTERVAL"))
+	}
+	c.BatchKey = os.Getenv("BATCH_KEY")
+	if c.BatchKey == "" {
+		c.BatchKey = "namespace+reason"
+	}
+	if _, err := newBatcher(c.BatchKey, 0); err != nil {
+		env.fail("BATCH_KEY", c.BatchKey, err)
+	}
+	c.BatchWindow = env.duration("BATCH_WINDOW", 0)
+	c.OutboxDir = os.Getenv("OUTBOX_DIR")
+	c.OutboxRetryInterval = env.duration("OUTBOX_RETRY_INTERVAL", 30*time.Second)
+	c.OutboxMaxAge = env.duration("OUTBOX_MAX_AGE", 24*time.Hour)
+	c.DeadLetterMaxAge = env.duration("DEAD_LETTER_MAX_AGE", 24*time.Hour)
+	c.AuditLogPath = os.Getenv("AUDIT_LOG_PATH")
+	c.AuditLogMaxSize = env.int("AUDIT_LOG_MAX_SIZE", 10)
+	c.HistoryExportPath = os.Getenv("HISTORY_EXPORT_PATH")
+	c.HistoryExportInterval = env.positiveDuration("HISTORY_EXPORT_INTERVAL", time.Minute)
+	c.HistoryExportMaxSize = env.positiveInt("HISTORY_EXPORT_MAX_SIZE", 100)
+	c.HistoryExportRotate = env.positiveDuration("HISTORY_EXPORT_ROTATE", 24*time.Hour)
+	c.HistoryExportKeep = env.int("HISTORY_EXPORT_KEEP", 7)
+	if c.HistoryExportKeep < 0 {
+		env.fail("HISTORY_EXPORT_KEEP", os.Getenv("HISTORY_EXPORT_KEEP"), errors.New("must not be negative"))
+	}
+	c.FailureAlertAfter = env.duration("FAILURE_ALERT_AFTER", 0)
+	c.FailureMarkerFile = os.Getenv("FAILURE_MARKER_FILE")
+	c.StartupSelfTest = env.bool("STARTUP_SELFTEST", false)
+	c.GRPCHealthAddr = os.Getenv("GRPC_HEALTH_ADDR")
+	c.SelfTestNamespace = os.Getenv("SELFTEST_NAMESPACE")
+	c.SuppressionLog = env.oneOf("SUPPRESSION_LOG", "off", "on")
+	c.SuppressionAlertRate = env.int("SUPPRESSION_ALERT_RATE", 0)
+	c.SuppressionAlertAfter = env.duration("SUPPRESSION_ALERT_AFTER", 10*time.Minute)
+	c.StormDetailLimit = env.int("STORM_DETAIL_LIMIT", 0)
+	c.StormSummaryInterval = env.duration("STORM_SUMMARY_INTERVAL", 2*time.Minute)
+	c.StormQuietPeriod = env.duration("STORM_QUIET_PERIOD", 5*time.Minute)
+	c.ThrottleKey = os.Getenv("THROTTLE_KEY")
+	c.ThrottleRate = env.float("THROTTLE_RATE", 0)
+	c.ThrottleBurst = env.positiveInt("THROTTLE_BURST", 5)
+	if c.ThrottleKey != "" {
+		if _, err := newThrottle(c.ThrottleKey, 0, 0); err != nil {
+			env.fail("THROTTLE_KEY", c.ThrottleKey, err)
+		}
+		if c.ThrottleRate <= 0 {
+			env.fail("THROTTLE_KEY", c.ThrottleKey, errors.New("requires THROTTLE_RATE"))
+		}
+	}
+	c.CriticalReasons = env.list("CRITICAL_REASONS", "OOMKilling", "NodeNotReady", "Evicted")
+	c.OffHoursMinSeverity = env.oneOf("OFF_HOURS_MIN_SEVERITY", "critical", "warning", "info")
+	if priorities, err := parsePriorities(env.pairs("PRIORITIES")); err != nil {
+		env.fail("PRIORITIES", os.Getenv("PRIORITIES"), err)
+	} else {
+		c.Priorities = priorities
+	}
+	c.SlackMention = os.Getenv("SLACK_MENTION")
+	if c.SlackMention == "" {
+		c.SlackMention = "<!here>"
+	}
+	if hours := os.Getenv("BUSINESS_HOURS"); hours != "" {
+		zone := os.Getenv("BUSINESS_HOURS_TZ")
+		location, err := time.LoadLocation(zone)
+		if err != nil {
+			env.fail("BUSINESS_HOURS_TZ", zone, err)
+		} else if c.BusinessHours, err = parseBusinessHours(hours, location); err != nil {
+			env.fail("BUSINESS_HOURS", hours, err)
+		}
+	}
+	if rules := os.Getenv("NOTIFY_RULES"); rules != "" {
+		var err error
+		if c.NotifyRules, err = parseRoutingRules(rules, c.NotifyTargets); err != nil {
+			env.fail("NOTIFY_RULES", rules, err)
+		}
+	}
+	c.IncludeControllerKinds = env.list("INCLUDE_CONTROLLER_KINDS")
+	c.JobAware = env.bool("JOB_AWARE", false)
+	if rules := os.Getenv("TYPE_REASON_RULES"); rules != "" {
+		var err error
+		if c.TypeReasonRules, err = parseTypeReasonRules(rules); err != nil {
+			env.fail("TYPE_REASON_RULES", rules, err)
+		}
+	}
+	if len(env.errs) > 0 {
+		return nil, errors.New(strings.Join(env.errs, "; "))
+	}
+	return c, nil
+}
+
+// envParser reads typed environment variables, collecting parse errors.
+type envParser struct {
+	errs []string
+}
+
+func (p *envParser) fail(name, value string, err error) {
+	p.errs = append(p.errs, fmt.Sprintf("invalid %s %q: %v", name, value, err))
+}
+
+func (p *envParser) bool(name string, def bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		p.fail(name, value, err)
+	}
+	return b
+}
+
+func (p *envParser) duration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		p.fail(name, value, err)
+	}
+	return d
+}
+
+// positiveDuration is duration for variables that must be above zero.
+func (p *envParser) positiveDuration(name string, def time.Duration) time.Duration {
+	d := p.duration(name, def)
+	if d <= 0 {
+		p.fail(name, os.Getenv(name), errors.New("must be positive"))
+	}
+	return d
+}
+
+// oneOf returns the variable's value, which must be one of the allowed
+// values, or the first of them when unset.
+func (p *envParser) oneOf(name string, allowed ...string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		return allowed[0]
+	}
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	p.fail(name, value, fmt.Errorf("must be one of %s", strings.Join(allowed, ", ")))
+	return allowed[0]
+}
+
+func (p *envParser) int(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		p.fail(name, value, err)
+	}
+	return i
+}
+
+// positiveInt is int for variables that must be at least 1.
+func (p *envParser) positiveInt(name string, def int) int {
+	i := p.int(name, def)
+	if i < 1 {
+		p.fail(name, os.Getenv(name), errors.New("must be at least 1"))
+	}
+	return i
+}
+
+func (p *envParser) float(name string, def float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		p.fail(name, value, err)
+	}
+	return f
+}
+
+// url returns the variable's value after checking it is an absolute URL.
+func (p *envParser) url(name string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		return ""
+	}
+	if u, err := url.Parse(value); err != nil {
+		p.fail(name, value, err)
+	} else if u.Scheme == "" || u.Host == "" {
+		p.fail(name, value, errors.New("not an absolute URL"))
+	}
+	return value
+}
+
+// list splits a comma separated variable, trimming blanks around entries.
+func (p *envParser) list(name string, def ...string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// pairs parses a comma separated list of key=value pairs.
+func (p *envParser) pairs(name string) map[string]string {
+	pairs := map[string]string{}
+	for _, entry := range p.list(name) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			p.fail(name, os.Getenv(name), fmt.Errorf("expected key=value, got %q", entry))
+			continue
+		}
+		pairs[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return pairs
+}
+
+// durations parses a comma separated list of durations.
+func (p *envParser) durations(name string) []time.Duration {
+	var durations []time.Duration
+	for _, entry := range p.list(name) {
+		d, err := time.ParseDuration(entry)
+		if err != nil {
+			p.fail(name, os.Getenv(name), err)
+			return nil
+		}
+		durations = append(durations, d)
+	}
+	return durations
+}
+
+// ints parses a comma separated list of integers.
+func (p *envParser) ints(name string) []int {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	var ints []int
+	for _, field := range strings.Split(value, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			p.fail(name, value, err)
+			return nil
+		}
+		ints = append(ints, i)
+	}
+	return ints
+}
+
+// secret reads a variable that may be mounted from a file, see secretEnv.
+func (p *envParser) secret(name string) string {
+	value, err := secretEnv(name)
+	if err != nil {
+		p.fail(name+"_FILE", os.Getenv(name+"_FILE"), err)
+	}
+	return value
+}
+
+// secretEnv returns the value of the named variable, preferring the contents
+// of the file named by its "_FILE" counterpart so secrets can be mounted
+// from a Secret instead of being written into the pod spec.
+func secretEnv(name string) (string, error) {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(content), " \t\r\n"), nil
+	}
+	return os.Getenv(name), nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigRejects(t *testing.T) {
+	for _, test := range []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{"ENRICHMENT_CACHE_SIZE": "0"}, "invalid ENRICHMENT_CACHE_SIZE"},
+		{map[string]string{"ENRICHMENT_CACHE_SIZE": "-5"}, "invalid ENRICHMENT_CACHE_SIZE"},
+		{map[string]string{"DEDUP_BACKOFF": "5m,30m"}, "requires DEDUP_TTL"},
+		{map[string]string{"WATCH_PING_FAILURES": "0"}, "invalid WATCH_PING_FAILURES"},
+		{map[string]string{"THROTTLE_BURST": "0"}, "invalid THROTTLE_BURST"},
+		{map[string]string{"HISTORY_EXPORT_INTERVAL": "0s"}, "invalid HISTORY_EXPORT_INTERVAL"},
+		{map[string]string{"HISTORY_EXPORT_ROTATE": "0s"}, "invalid HISTORY_EXPORT_ROTATE"},
+		{map[string]string{"HISTORY_EXPORT_MAX_SIZE": "0"}, "invalid HISTORY_EXPORT_MAX_SIZE"},
+		{map[string]string{"HISTORY_EXPORT_KEEP": "-1"}, "invalid HISTORY_EXPORT_KEEP"},
+	} {
+		t.Run(test.want, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("%v: got error %v, want %q", test.env, err, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.EnrichmentCacheSize != 1000 {
+		t.Errorf("ENRICHMENT_CACHE_SIZE defaults to %d", c.EnrichmentCacheSize)
+	}
+}
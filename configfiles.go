@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// applyConfigFiles reads the YAML or JSON files listed in CONFIG_FILES,
+// separated by colons, each an object keyed by environment variable name:
+//
+//	DEDUP_TTL: 10m
+//	NOTIFY_TARGETS: [slack, webhook]
+//	REASON_TEMPLATES:
+//	  BackOff: "{{.Name}} keeps crashing"
+//
+// The files are merged in order, later ones overriding earlier ones:
+// objects are merged key by key, recursively, while scalars and lists are
+// replaced as a whole. The merged values are then exported as the
+// variables they are named after, except for those set in the environment,
+// which override every file. Lists become comma separated and objects JSON,
+// the format the variables take; the result is validated by loadConfig
+// like any other configuration.
+func applyConfigFiles() error {
+	value := os.Getenv("CONFIG_FILES")
+	if value == "" {
+		return nil
+	}
+	merged := map[string]interface{}{}
+	for _, path := range strings.Split(value, ":") {
+		if path == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var layer map[string]interface{}
+		if err := yaml.Unmarshal(content, &layer); err != nil {
+			return fmt.Errorf("invalid config file %s: %v", path, err)
+		}
+		mergeConfig(merged, layer)
+	}
+	for name, value := range merged {
+		if _, set := os.LookupEnv(name); set {
+			continue
+		}
+		text, err := configValue(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s in CONFIG_FILES: %v", name, err)
+		}
+		os.Setenv(name, text)
+	}
+	return nil
+}
+
+// mergeConfig merges layer into base, deep-merging objects and replacing
+// everything else.
+func mergeConfig(base, layer map[string]interface{}) {
+	for key, value := range layer {
+		object, isObject := value.(map[string]interface{})
+		baseObject, baseIsObject := base[key].(map[string]interface{})
+		if isObject && baseIsObject {
+			mergeConfig(baseObject, object)
+			continue
+		}
+		base[key] = value
+	}
+}
+
+// configValue renders a merged value as its variable expects it.
+func configValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case float64:
+		// Numbers are decoded as float64, which fmt would print as
+		// 1e+06 rather than the 1000000 the variable parses.
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case []interface{}:
+		entries := make([]string, len(value))
+		for i, entry := range value {
+			text, err := configValue(entry)
+			if err != nil {
+				return "", err
+			}
+			entries[i] = text
+		}
+		return strings.Join(entries, ","), nil
+	case map[string]interface{}:
+		content, err := json.Marshal(value)
+		return string(content), err
+	case nil:
+		return "", nil
+	}
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestConfigValue(t *testing.T) {
+	for _, test := range []struct {
+		value interface{}
+		want  string
+	}{
+		{"10m", "10m"},
+		{float64(1000000), "1000000"},
+		{float64(0.5), "0.5"},
+		{true, "true"},
+		{[]interface{}{"slack", float64(2000000)}, "slack,2000000"},
+		{map[string]interface{}{"BackOff": "crashing"}, `{"BackOff":"crashing"}`},
+		{nil, ""},
+	} {
+		got, err := configValue(test.value)
+		if err != nil {
+			t.Errorf("%v: %v", test.value, err)
+		} else if got != test.want {
+			t.Errorf("%v: got %q, want %q", test.value, got, test.want)
+		}
+	}
+}
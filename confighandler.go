@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// effectiveMappings shows how events are classified and rendered once the
+// defaults and the configuration are combined.
+type effectiveMappings struct {
+	// Severities maps event types, and the reasons overriding them, to
+	// severities.
+	Severities map[string]string `json:"severities"`
+	// Colors maps event types, and the conditions overriding them, to
+	// Slack attachment colors.
+	Colors map[string]string `json:"colors"`
+	// Priorities maps severities to priorities.
+	Priorities map[string]string `json:"priorities"`
+	// WebhookHeaders lists the extra webhook request headers, with their
+	// values redacted.
+	WebhookHeaders map[string]string `json:"webhookHeaders,omitempty"`
+	// Rules lists NOTIFY_RULES in evaluation order.
+	Rules []routingRule `json:"rules,omitempty"`
+}
+
+// configHandler reports the effective mappings as JSON. The rest of the
+// configuration is not exposed, as it holds secrets.
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	mappings := effectiveMappings{
+		Severities: map[string]string{"Normal": "info", "Warning": "warning"},
+		Colors:     map[string]string{"Normal": "good", "Warning": "warning", "OOMKilled": oomColor},
+		Priorities: map[string]string{},
+		Rules:      cfg.NotifyRules,
+	}
+	if len(cfg.WebhookHeaders) > 0 {
+		mappings.WebhookHeaders = map[string]string{}
+		for name := range cfg.WebhookHeaders {
+			mappings.WebhookHeaders[name] = "REDACTED"
+		}
+	}
+	for _, threshold := range cfg.CountColors {
+		mappings.Colors[fmt.Sprintf("Count>=%d", threshold.Count)] = threshold.Color
+	}
+	for _, reason := range cfg.CriticalReasons {
+		mappings.Severities["Warning:"+reason] = "critical"
+	}
+	for _, severity := range severities {
+		p := priorityNormal
+		if configured, ok := cfg.Priorities[severity]; ok {
+			p = configured
+		}
+		for name, value := range priorityNames {
+			if value == p {
+				mappings.Priorities[severity] = name
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mappings)
+}
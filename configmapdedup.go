@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// namespaceFile holds the namespace of the pod's service account.
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// configMapRetries bounds the attempts at an update conflicting with other
+// replicas.
+const configMapRetries = 5
+
+// configMapDedup keeps the dedup entries in a ConfigMap of the pod's
+// namespace as well as in memory, so they survive restarts and are shared
+// by all replicas. Claims read and update the ConfigMap with optimistic
+// concurrency, retrying when another replica updated it in between, so only
+// one replica notifies a key. Duplicates are checked against the memory
+// copy, which claims refresh from the ConfigMap.
+//
+// Keys are hashed, ConfigMap keys being restricted to a few characters, and
+// when duplicates were last seen is only saved along with a claim, as
+// saving every duplicate would cost an API call each.
+type configMapDedup struct {
+	namespace string
+	name      string
+	local     *dedupCache
+}
+
+// storedDedupEntry is a dedup entry as saved in the ConfigMap.
+type storedDedupEntry struct {
+	Notified      time.Time `json:"notified"`
+	Suppressed    time.Time `json:"suppressed,omitempty"`
+	Notifications int       `json:"notifications,omitempty"`
+}
+
+// newConfigMapDedup loads the entries saved by a previous run or another
+// replica.
+func newConfigMapDedup(name string, ttl, ongoing time.Duration, backoff []time.Duration) (*configMapDedup, error) {
+	namespace, err := ioutil.ReadFile(namespaceFile)
+	if err != nil {
+		return nil, err
+	}
+	c := &configMapDedup{namespace: strings.TrimSpace(string(namespace)), name: name, local: newDedupCache(ttl, ongoing, backoff)}
+	loaded := 0
+	err = c.modify(func(entries map[string]storedDedupEntry, now time.Time) bool {
+		for key, e := range entries {
+			c.local.adopt(key, e)
+			loaded++
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded %d dedup entries from ConfigMap %s/%s", loaded, c.namespace, c.name)
+	return c, nil
+}
+
+func dedupHash(key string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(key)))[:32]
+}
+
+func (c *configMapDedup) suppressed(key string) bool {
+	return c.local.suppressed(dedupHash(key))
+}
+
+func (c *configMapDedup) peek(key string) bool {
+	return c.local.peek(dedupHash(key))
+}
+
+// claim wins key unless this or another replica notified it recently. When
+// the ConfigMap can't be updated the claim falls back to memory only, as
+// notifying twice beats not notifying.
+func (c *configMapDedup) claim(key string) bool {
+	hash := dedupHash(key)
+	if c.local.suppressed(hash) {
+		return false
+	}
+	won := true
+	err := c.modify(func(entries map[string]storedDedupEntry, now time.Time) bool {
+		won = true
+		if e, ok := entries[hash]; ok && c.local.holds(e.entry(), now) {
+			c.local.adopt(hash, e)
+			won = false
+			return false
+		}
+		entries[hash] = c.local.stored(hash, now)
+		return true
+	})
+	if err != nil {
+		log.Printf("Unable to claim %s in the dedup ConfigMap: %v", key, err)
+		return c.local.claim(hash)
+	}
+	if won {
+		c.local.record(hash)
+	} else {
+		c.local.suppressed(hash)
+	}
+	return won
+}
+
+func (c *configMapDedup) release(key string) {
+	hash := dedupHash(key)
+	c.local.release(hash)
+	err := c.modify(func(entries map[string]storedDedupEntry, now time.Time) bool {
+		if _, ok := entries[hash]; !ok {
+			return false
+		}
+		delete(entries, hash)
+		return true
+	})
+	if err != nil {
+		log.Printf("Unable to release %s in the dedup ConfigMap: %v", key, err)
+	}
+}
+
+func (c *configMapDedup) record(key string) {
+	hash := dedupHash(key)
+	err := c.modify(func(entries map[string]storedDedupEntry, now time.Time) bool {
+		entries[hash] = c.local.stored(hash, now)
+		return true
+	})
+	if err != nil {
+		log.Printf("Unable to record %s in the dedup ConfigMap: %v", key, err)
+	}
+	c.local.record(hash)
+}
+
+// lastCount and recordCount only use the memory copy, the counts being for
+// display.
+func (c *configMapDedup) lastCount(key string) int32 {
+	return c.local.lastCount(dedupHash(key))
+}
+
+func (c *configMapDedup) recordCount(key string, count int32) {
+	c.local.recordCount(dedupHash(key), count)
+}
+
+// modify reads the saved entries, applies change and, when it reports a
+// change, saves them without those that expired. An update conflicting
+// with another replica's is retried from a fresh read.
+func (c *configMapDedup) modify(change func(entries map[string]storedDedupEntry, now time.Time) bool) error {
+	configMaps := currentClientset().Core().ConfigMaps(c.namespace)
+	for attempt := 0; attempt < configMapRetries; attempt++ {
+		configMap, err := configMaps.Get(c.name)
+		create := apierrors.IsNotFound(err)
+		if create {
+			configMap = &v1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: c.name, Namespace: c.namespace}}
+		} else if err != nil {
+			return err
+		}
+
+		entries := map[string]storedDedupEntry{}
+		for key, value := range configMap.Data {
+			var e storedDedupEntry
+			if json.Unmarshal([]byte(value), &e) == nil {
+				entries[key] = e
+			}
+		}
+		now := time.Now()
+		if !change(entries, now) {
+			return nil
+		}
+
+		configMap.Data = map[string]string{}
+		for key, e := range entries {
+			if !c.local.holds(e.entry(), now) {
+				continue
+			}
+			value, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			configMap.Data[key] = string(value)
+		}
+		if create {
+			_, err = configMaps.Create(configMap)
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+		} else {
+			_, err = configMaps.Update(configMap)
+			if apierrors.IsConflict(err) {
+				continue
+			}
+		}
+		return err
+	}
+	return errors.New("too many conflicting updates")
+}
+
+// adopt sets the entry of key from a saved one, keeping the most recent
+// times.
+func (c *dedupCache) adopt(key string, saved storedDedupEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = &dedupEntry{}
+		c.entries[key] = e
+	}
+	if saved.Notified.After(e.notified) {
+		e.notified = saved.Notified
+	}
+	if saved.Suppressed.After(e.suppressed) {
+		e.suppressed = saved.Suppressed
+	}
+	if saved.Notifications > e.notifications {
+		e.notifications = saved.Notifications
+	}
+}
+
+func (e storedDedupEntry) entry() *dedupEntry {
+	return &dedupEntry{notified: e.Notified, suppressed: e.Suppressed, notifications: e.Notifications}
+}
+
+// stored returns the entry of key to save, as notified at now.
+func (c *dedupCache) stored(key string, now time.Time) storedDedupEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	saved := storedDedupEntry{Notified: now, Notifications: 1}
+	if e, ok := c.entries[key]; ok {
+		saved.Suppressed = e.suppressed
+		if now.Sub(e.suppressed) < c.cooldown(e) {
+			saved.Notifications = e.notifications + 1
+		}
+	}
+	return saved
+}
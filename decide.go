@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// decision is what becomes of an event: "notify", "digest", "batch" or
+// "incident", or else why it is suppressed, as counted by
+// events_suppressed_total.
+type decision struct {
+	action  string
+	key     string
+	urgent  bool
+	targets multiNotifier
+}
+
+// decide runs the event through the checks of the pipeline in order, the
+// single decision chain of handleEvent and /simulate. A dry run only looks
+// at the state of deduplication, series, storms, throttles and flaps,
+// while a real one counts the event towards them, follows its series and
+// comments on its open incident.
+func decide(clientset *kubernetes.Clientset, event *v1.Event, dry bool) decision {
+	p := priorityOf(event)
+	d := decision{urgent: p == priorityHigh, targets: notifier.routed(event)}
+	d.key = dedupKey(event)
+	if cfg.DedupPerGeneration {
+		d.key += "/" + generationOf(clientset, event)
+	}
+	d.key = priorityKey(d.key, event, p)
+	d.action = d.check(clientset, event, p, dry)
+	return d
+}
+
+func (d *decision) check(clientset *kubernetes.Clientset, event *v1.Event, p priority, dry bool) string {
+	if series != nil {
+		if dry && series.wouldFollow(event) || !dry && series.follow(clientset, event) {
+			return "series"
+		}
+	}
+	if cfg.NormalAfterWarning && event.Type == "Normal" && !dedup.peek(warningKey(event)) {
+		return "no-prior-warning"
+	}
+	if jobCompleted(event) {
+		return "job-completed"
+	}
+	if !matchesControllerKind(clientset, event) {
+		return "controller-kind"
+	}
+	if dry && d.targets.wouldSuppress(d.key) || !dry && d.targets.delivered(d.key) {
+		return "duplicate"
+	}
+	if cfg.SkipTerminatingNamespaces && event.InvolvedObject.Namespace != "" && namespaceTerminating(clientset, event) {
+		return "terminating-namespace"
+	}
+	if !d.urgent && cfg.StartupWarningGrace > 0 && inStartupGrace(clientset, event) {
+		return "startup-grace"
+	}
+	if !d.urgent && offHours(event, time.Now()) {
+		if digests != nil {
+			return "digest"
+		}
+		return "off-hours"
+	}
+	if storms != nil {
+		if dry && !storms.wouldAdmit(event) || !dry && !storms.admit(event) {
+			return "storm"
+		}
+	}
+	if throttles != nil {
+		if dry && !throttles.wouldAdmit(clientset, event) || !dry && !throttles.admit(clientset, event) {
+			return "throttle"
+		}
+	}
+	if flaps != nil {
+		if dry && flaps.wouldHold(event) || !dry && flaps.held(event) {
+			return "flapping"
+		}
+	}
+	if id, err := incidents.OpenIncident(event); err != nil {
+		log.Printf("Unable to look up incidents about %s/%s: %v", event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+	} else if id != "" {
+		if dry {
+			return "incident"
+		}
+		err := incidents.Comment(id, event, enrichEvent(clientset, event))
+		if err == nil {
+			return "incident"
+		}
+		log.Printf("Unable to add %s on %s/%s to incident %s: %v", event.Reason, event.InvolvedObject.Namespace, event.InvolvedObject.Name, id, err)
+	}
+	if digested(clientset, event, p) {
+		return "digest"
+	}
+	if !d.urgent && batches != nil {
+		return "batch"
+	}
+	return "notify"
+}
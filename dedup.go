@@ -0,0 +1,272 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// dedupStore remembers which keys were notified. dedupCache keeps them in
+// memory, configMapDedup also shares them with other replicas and across
+// restarts through a ConfigMap.
+type dedupStore interface {
+	suppressed(key string) bool
+	peek(key string) bool
+	claim(key string) bool
+	release(key string)
+	record(key string)
+	// lastCount is the event count key was last notified with, 0 if
+	// unknown.
+	lastCount(key string) int32
+	recordCount(key string, count int32)
+}
+
+// dedupCache remembers recently notified events so the same problem is not
+// posted again until its TTL expires. With an ongoing interval, a problem
+// that kept recurring up to the TTL's expiry is considered still ongoing
+// rather than new, and is only notified again once that longer interval
+// has passed since its last notification.
+//
+// With a backoff, the TTL is replaced by a cooldown growing with every
+// notification of a problem that kept recurring, e.g. 1m, 5m, then 15m
+// between reminders, and reset once the problem stops recurring for a
+// whole cooldown.
+type dedupCache struct {
+	ttl     time.Duration
+	ongoing time.Duration
+	backoff []time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*dedupEntry
+	counts    map[string]notifiedCount
+	nextSweep time.Time
+}
+
+// notifiedCount is the event count a key was last notified with. Counts
+// outlive the entries, which are gone by the time a problem is notified
+// again, and are kept for countRetention.
+type notifiedCount struct {
+	count    int32
+	notified time.Time
+}
+
+const countRetention = 24 * time.Hour
+
+type dedupEntry struct {
+	notified time.Time
+	// suppressed is when a duplicate was last held back.
+	suppressed time.Time
+	// notifications counts the notifications of a recurring problem.
+	notifications int
+}
+
+func newDedupCache(ttl, ongoing time.Duration, backoff []time.Duration) *dedupCache {
+	return &dedupCache{ttl: ttl, ongoing: ongoing, backoff: backoff, entries: map[string]*dedupEntry{}, counts: map[string]notifiedCount{}}
+}
+
+// cooldown is how long after its last notification the entry suppresses
+// duplicates.
+func (c *dedupCache) cooldown(e *dedupEntry) time.Duration {
+	if len(c.backoff) == 0 {
+		return c.ttl
+	}
+	step := e.notifications - 1
+	if step < 0 {
+		step = 0
+	}
+	if step >= len(c.backoff) {
+		step = len(c.backoff) - 1
+	}
+	return c.backoff[step]
+}
+
+// holds reports whether the entry still suppresses duplicates: within the
+// TTL of its notification, or within the ongoing interval as long as the
+// duplicates never stopped for a whole TTL.
+func (c *dedupCache) holds(e *dedupEntry, now time.Time) bool {
+	if now.Before(e.notified.Add(c.cooldown(e))) {
+		return true
+	}
+	return c.ongoing > 0 && now.Sub(e.suppressed) < c.ttl && now.Before(e.notified.Add(c.ongoing))
+}
+
+// suppressed reports whether key is a duplicate of a recent notification.
+func (c *dedupCache) suppressed(key string) bool {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || !c.holds(e, now) {
+		return false
+	}
+	e.suppressed = now
+	return true
+}
+
+// peek reports whether key is a duplicate without counting it as one.
+func (c *dedupCache) peek(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	return ok && c.holds(e, time.Now())
+}
+
+// claim atomically marks key as notified unless it is a duplicate,
+// reporting whether the caller won it. Unlike separate suppressed and
+// record calls, this leaves no window in which a duplicate delivered right
+// after a reconnect can be sent twice. A failed send gives the key back
+// with release.
+func (c *dedupCache) claim(key string) bool {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok && c.holds(e, now) {
+		e.suppressed = now
+		return false
+	}
+	c.sweep(now)
+	c.notify(key, now)
+	return true
+}
+
+func (c *dedupCache) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// record marks key as notified.
+func (c *dedupCache) record(key string) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sweep(now)
+	c.notify(key, now)
+}
+
+func (c *dedupCache) lastCount(key string) int32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[key].count
+}
+
+func (c *dedupCache) recordCount(key string, count int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[key] = notifiedCount{count: count, notified: time.Now()}
+}
+
+// notify sets the notification time of key, keeping when its duplicates
+// were last seen, and counts the notification if they were seen within the
+// last cooldown. The caller holds c.mu.
+func (c *dedupCache) notify(key string, now time.Time) {
+	e, ok := c.entries[key]
+	if !ok {
+		e = &dedupEntry{}
+		c.entries[key] = e
+	}
+	if now.Sub(e.suppressed) < c.cooldown(e) {
+		e.notifications++
+	} else {
+		e.notifications = 1
+	}
+	e.notified = now
+}
+
+// sweep drops entries that no longer suppress anything, or with a backoff
+// that no longer count toward it either. It runs at most once per TTL rather
+// than on every call, which would make storms quadratic. The caller holds
+// c.mu.
+func (c *dedupCache) sweep(now time.Time) {
+	if now.Before(c.nextSweep) {
+		return
+	}
+	for k, e := range c.entries {
+		if c.holds(e, now) {
+			continue
+		}
+		if len(c.backoff) > 0 && now.Sub(e.suppressed) < c.cooldown(e) {
+			continue
+		}
+		delete(c.entries, k)
+	}
+	for k, n := range c.counts {
+		if now.Sub(n.notified) > countRetention {
+			delete(c.counts, k)
+		}
+	}
+	c.nextSweep = now.Add(c.ttl)
+}
+
+var digits = regexp.MustCompile(`[0-9]+`)
+
+// dedupKey identifies an event by its object, reason and message. With
+// DEDUP_IGNORE_NUMBERS digit runs in the message are masked so messages such
+// as restart counts that only differ by a number are treated as the same.
+// With DEDUP_COUNT_BUCKETS the key also carries how many bucket boundaries
+// the event's count has crossed, so a worsening problem is notified again.
+// By default (DEDUP_SCOPE=namespace) the object is left out of the key, so
+// the same message about any object of a namespace is notified once; with
+// DEDUP_SCOPE=cluster the namespace is left out too.
+func dedupKey(event *v1.Event) string {
+	message := event.Message
+	if cfg.DedupIgnoreNumbers {
+		message = digits.ReplaceAllString(message, "#")
+	}
+	var parts []string
+	if event.ClusterName != "" {
+		parts = append(parts, event.ClusterName)
+	}
+	// Events without a message are keyed on their reason and object only,
+	// whatever the scope.
+	empty := strings.TrimSpace(message) == ""
+	if cfg.DedupScope != "cluster" || empty {
+		parts = append(parts, event.InvolvedObject.Namespace)
+	}
+	if empty {
+		parts = append(parts, event.InvolvedObject.Kind, event.InvolvedObject.Name)
+	}
+	parts = append(parts, canonicalReason(event.Reason))
+	if !empty {
+		parts = append(parts, message)
+	}
+	if dedupByNode(event) {
+		parts = append(parts, event.Source.Host)
+	}
+	if len(cfg.DedupCountBuckets) > 0 {
+		parts = append(parts, strconv.Itoa(countBucket(event.Count)))
+	}
+	return strings.Join(parts, "/")
+}
+
+// dedupByNode reports whether the node that reported the event is part of
+// its dedup key, i.e. whether its reason is one of DEDUP_NODE_REASONS.
+func dedupByNode(event *v1.Event) bool {
+	for _, reason := range cfg.DedupNodeReasons {
+		if reason == "*" || canonicalReason(reason) == canonicalReason(event.Reason) {
+			return true
+		}
+	}
+	return false
+}
+
+func countBucket(count int32) int {
+	bucket := 0
+	for _, boundary := range cfg.DedupCountBuckets {
+		if int(count) >= boundary {
+			bucket++
+		}
+	}
+	return bucket
+}
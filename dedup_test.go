@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// withConfig sets cfg to c for the duration of the test.
+func withConfig(t testing.TB, c *Config) {
+	saved := cfg
+	cfg = c
+	t.Cleanup(func() { cfg = saved })
+}
+
+func testEvent(namespace, kind, name, reason, message string) *v1.Event {
+	return &v1.Event{
+		InvolvedObject: v1.ObjectReference{Namespace: namespace, Kind: kind, Name: name},
+		Type:           "Warning",
+		Reason:         reason,
+		Message:        message,
+		Count:          1,
+	}
+}
+
+func TestDedupKeyIgnoresNumbers(t *testing.T) {
+	first := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container, 3 times")
+	second := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container, 12 times")
+	other := testEvent("app", "Pod", "web-1", "BackOff", "Back-off pulling image, 3 times")
+
+	withConfig(t, &Config{DedupIgnoreNumbers: true})
+	if dedupKey(first) != dedupKey(second) {
+		t.Errorf("messages differing only in numbers have different keys: %q and %q", dedupKey(first), dedupKey(second))
+	}
+	if dedupKey(first) == dedupKey(other) {
+		t.Errorf("different messages share the key %q", dedupKey(first))
+	}
+
+	withConfig(t, &Config{})
+	if dedupKey(first) == dedupKey(second) {
+		t.Errorf("numbers are ignored without DEDUP_IGNORE_NUMBERS: %q", dedupKey(first))
+	}
+}
+
+func TestDedupKeyScope(t *testing.T) {
+	event := testEvent("team-a", "Pod", "web-1", "FailedMount", "secret \"db\" not found")
+	sameNamespace := testEvent("team-a", "Pod", "web-2", "FailedMount", "secret \"db\" not found")
+	otherNamespace := testEvent("team-b", "Pod", "api-7", "FailedMount", "secret \"db\" not found")
+	otherCluster := testEvent("team-a", "Pod", "web-1", "FailedMount", "secret \"db\" not found")
+	otherCluster.ClusterName = "east"
+	otherMessage := testEvent("team-a", "Pod", "web-1", "FailedMount", "secret \"cache\" not found")
+
+	withConfig(t, &Config{DedupScope: "namespace"})
+	if dedupKey(event) != dedupKey(sameNamespace) {
+		t.Errorf("namespace scope: %q and %q are not shared within the namespace", dedupKey(event), dedupKey(sameNamespace))
+	}
+	for _, e := range []*v1.Event{otherNamespace, otherCluster, otherMessage} {
+		if dedupKey(event) == dedupKey(e) {
+			t.Errorf("namespace scope: %s/%s shares the key %q", e.InvolvedObject.Namespace, e.InvolvedObject.Name, dedupKey(e))
+		}
+	}
+
+	withConfig(t, &Config{DedupScope: "cluster"})
+	for _, e := range []*v1.Event{sameNamespace, otherNamespace} {
+		if dedupKey(event) != dedupKey(e) {
+			t.Errorf("cluster scope: %s/%s has the key %q, want %q", e.InvolvedObject.Namespace, e.InvolvedObject.Name, dedupKey(e), dedupKey(event))
+		}
+	}
+	for _, e := range []*v1.Event{otherCluster, otherMessage} {
+		if dedupKey(event) == dedupKey(e) {
+			t.Errorf("cluster scope: %q is shared across clusters or messages", dedupKey(e))
+		}
+	}
+}
+
+func TestDedupKeyEmptyMessage(t *testing.T) {
+	withConfig(t, &Config{EmptyMessage: "(no message)"})
+	event := testEvent("app", "Pod", "web-1", "Unhealthy", "")
+	if got, want := dedupKey(event), "app/Pod/web-1/Unhealthy"; got != want {
+		t.Errorf("key %q, want %q", got, want)
+	}
+	if dedupKey(event) == dedupKey(testEvent("app", "Pod", "web-2", "Unhealthy", "")) {
+		t.Error("empty messages of different objects share a key")
+	}
+}
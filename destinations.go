@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// slackDestination is a named Slack webhook or channel, typically living in
+// its own workspace, events can be routed to. A destination with a token is
+// posted to through the Web API of its workspace, one without a token or a
+// webhook through SLACK_BOT_TOKEN.
+type slackDestination struct {
+	Webhook string `json:"webhook"`
+	Token   string `json:"token"`
+	Channel string `json:"channel"`
+}
+
+// slackRouting holds the SLACK_DESTINATIONS the NOTIFY_RULES route to.
+type slackRouting struct {
+	destinations map[string]slackDestination
+}
+
+var (
+	routingMu sync.RWMutex
+	routing   *slackRouting
+)
+
+func currentRouting() *slackRouting {
+	routingMu.RLock()
+	defer routingMu.RUnlock()
+	return routing
+}
+
+// loadRouting reads SLACK_DESTINATIONS, a JSON object of named destinations
+// the NOTIFY_RULES route to, which may be mounted as a file through
+// SLACK_DESTINATIONS_FILE. Routing to destinations stays disabled when none
+// are configured.
+func loadRouting() error {
+	if os.Getenv("SLACK_ROUTES") != "" || os.Getenv("SLACK_ROUTES_FILE") != "" {
+		return fmt.Errorf("SLACK_ROUTES is replaced by the destinations of NOTIFY_RULES")
+	}
+	destinations, err := secretEnv("SLACK_DESTINATIONS")
+	if err != nil {
+		return err
+	}
+	r := &slackRouting{}
+	if destinations != "" {
+		if err := json.Unmarshal([]byte(destinations), &r.destinations); err != nil {
+			return fmt.Errorf("invalid SLACK_DESTINATIONS: %v", err)
+		}
+	}
+	if err := r.validate(); err != nil {
+		return err
+	}
+	if destinations == "" {
+		r = nil
+	}
+
+	routingMu.Lock()
+	routing = r
+	routingMu.Unlock()
+	return nil
+}
+
+func (r *slackRouting) validate() error {
+	for name, destination := range r.destinations {
+		switch {
+		case destination.Token != "" && destination.Webhook != "":
+			return fmt.Errorf("destination %q has both a webhook and a token", name)
+		case destination.Token != "" || (destination.Webhook == "" && currentBotToken() != ""):
+			// Posted to its channel through the Web API.
+			if destination.Token != "" && !strings.HasPrefix(destination.Token, "xox") {
+				return fmt.Errorf("destination %q has an invalid token, expected a Slack token starting with xox", name)
+			}
+			if destination.Channel == "" && (destination.Token != "" || cfg.SlackChannel == "") {
+				return fmt.Errorf("destination %q posts through the Web API and needs a channel", name)
+			}
+		default:
+			if u, err := url.Parse(destination.Webhook); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("destination %q has an invalid webhook", name)
+			}
+		}
+	}
+	for i, rule := range cfg.NotifyRules {
+		for _, name := range rule.Destinations {
+			if _, ok := r.destinations[name]; !ok {
+				return fmt.Errorf("NOTIFY_RULES rule %d routes to unknown destination %q", i, name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// digest accumulates events and posts them as one summary per interval
+// instead of one message per event.
+type digest struct {
+	groupBy string
+
+	mu     sync.Mutex
+	groups map[string]*digestGroup
+}
+
+// digestGroup is one section of the summary. Namespace or Reason is empty
+// when the digest is not grouped by it. Sections are always per cluster.
+type digestGroup struct {
+	Cluster   string
+	Namespace string
+	Reason    string
+	Count     int
+	Objects   map[string]bool
+}
+
+// newDigest returns a digest grouping events by DIGEST_GROUP_BY, one of
+// "namespace", "reason" or "namespace+reason".
+func newDigest(groupBy string) *digest {
+	return &digest{groupBy: groupBy, groups: map[string]*digestGroup{}}
+}
+
+// digestsPerNamespace reports whether only the digest-only namespaces are
+// digested, the others being notified in real time.
+func digestsPerNamespace() bool {
+	return len(cfg.DigestNamespaces) > 0 || cfg.DigestAnnotation != ""
+}
+
+// digested reports whether the event goes to the digest rather than being
+// notified in real time. Without digest-only namespaces every event but the
+// high priority ones is digested; with them, every event of those namespaces
+// is, whatever its priority, and of the others only the low priority ones.
+func digested(clientset *kubernetes.Clientset, event *v1.Event, p priority) bool {
+	if digests == nil {
+		return false
+	}
+	if !digestsPerNamespace() {
+		return p != priorityHigh
+	}
+	return p == priorityLow || digestOnly(clientset, event.InvolvedObject.Namespace)
+}
+
+// digestOnly reports whether the namespace matches DIGEST_NAMESPACES or
+// has DIGEST_ANNOTATION set to "true".
+func digestOnly(clientset *kubernetes.Clientset, namespace string) bool {
+	if namespace == "" {
+		return false
+	}
+	if len(cfg.DigestNamespaces) > 0 && matchesAny(cfg.DigestNamespaces, namespace) {
+		return true
+	}
+	if cfg.DigestAnnotation == "" {
+		return false
+	}
+	object, err := lookupObject(clientset, "", "Namespace", namespace)
+	if err != nil {
+		log.Printf("Unable to look up namespace %s for its digest annotation: %v", namespace, err)
+		return false
+	}
+	return object.(*v1.Namespace).Annotations[cfg.DigestAnnotation] == "true"
+}
+
+func (d *digest) add(event *v1.Event) {
+	group := digestGroup{Cluster: event.ClusterName}
+	if d.groupBy != "reason" {
+		group.Namespace = event.InvolvedObject.Namespace
+	}
+	if d.groupBy != "namespace" {
+		group.Reason = event.Reason
+	}
+	key := group.Cluster + "/" + group.Namespace + "/" + canonicalReason(group.Reason)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	g, ok := d.groups[key]
+	if !ok {
+		group.Objects = map[string]bool{}
+		g = &group
+		d.groups[key] = g
+	}
+	g.Count++
+	g.Objects[event.ClusterName+"/"+event.InvolvedObject.Namespace+"/"+event.InvolvedObject.Kind+"/"+event.InvolvedObject.Name] = true
+}
+
+// take returns the accumulated groups, busiest first, and resets the digest.
+func (d *digest) take() []*digestGroup {
+	d.mu.Lock()
+	groups := make([]*digestGroup, 0, len(d.groups))
+	for _, g := range d.groups {
+		groups = append(groups, g)
+	}
+	d.groups = map[string]*digestGroup{}
+	d.mu.Unlock()
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Cluster+groups[i].Namespace+groups[i].Reason < groups[j].Cluster+groups[j].Namespace+groups[j].Reason
+	})
+	return groups
+}
+
+func (d *digest) message(groups []*digestGroup, interval time.Duration) SlackMessage {
+	message := SlackMessage{}
+	for _, g := range groups {
+		var title []string
+		if len(cfg.ClusterContexts) > 1 {
+			title = append(title, g.Cluster)
+		}
+		if g.Namespace != "" {
+			title = append(title, g.Namespace)
+		}
+		if g.Reason != "" {
+			title = append(title, g.Reason)
+		}
+		message.Attachments = append(message.Attachments, SlackAttachment{
+			Color: "warning",
+			Title: strings.Join(title, " / "),
+			Text:  fmt.Sprintf("%d events on %d objects in the last %v", g.Count, len(g.Objects), interval),
+		})
+	}
+	return message
+}
+
+// run posts the digest every interval, skipping empty ones.
+func (d *digest) run(interval time.Duration) {
+	for range time.Tick(interval) {
+		groups := d.take()
+		if len(groups) == 0 {
+			continue
+		}
+		if err := postDefault(d.message(groups, interval)); err != nil {
+			log.Printf("Unable to post the digest: %v", err)
+		}
+	}
+}
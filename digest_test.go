@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDigestGrouping(t *testing.T) {
+	withConfig(t, &Config{NormalizeReasons: true})
+	events := []struct{ namespace, name, reason string }{
+		{"app", "web-1", "BackOff"},
+		{"app", "web-1", "BackOff"},
+		{"app", "web-2", "BackOff"},
+		{"app", "web-2", "FailedMount"},
+		{"db", "pg-0", "BackOff"},
+	}
+	for _, test := range []struct {
+		groupBy string
+		want    []digestGroup
+	}{
+		{"namespace", []digestGroup{
+			{Namespace: "app", Count: 4},
+			{Namespace: "db", Count: 1},
+		}},
+		{"reason", []digestGroup{
+			{Reason: "BackOff", Count: 4},
+			{Reason: "FailedMount", Count: 1},
+		}},
+		{"namespace+reason", []digestGroup{
+			{Namespace: "app", Reason: "BackOff", Count: 3},
+			{Namespace: "app", Reason: "FailedMount", Count: 1},
+			{Namespace: "db", Reason: "BackOff", Count: 1},
+		}},
+	} {
+		d := newDigest(test.groupBy)
+		for _, e := range events {
+			d.add(testEvent(e.namespace, "Pod", e.name, e.reason, ""))
+		}
+		var got []digestGroup
+		for _, g := range d.take() {
+			got = append(got, digestGroup{Namespace: g.Namespace, Reason: g.Reason, Count: g.Count})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("grouped by %s: got %+v, want %+v", test.groupBy, got, test.want)
+		}
+		if groups := d.take(); len(groups) != 0 {
+			t.Errorf("grouped by %s: %d groups left after take", test.groupBy, len(groups))
+		}
+	}
+}
+
+func TestDigestCountsDistinctObjects(t *testing.T) {
+	withConfig(t, &Config{})
+	d := newDigest("namespace+reason")
+	d.add(testEvent("app", "Pod", "web-1", "BackOff", ""))
+	d.add(testEvent("app", "Pod", "web-1", "BackOff", ""))
+	d.add(testEvent("app", "Pod", "web-2", "BackOff", ""))
+
+	message := d.message(d.take(), time.Hour)
+	if len(message.Attachments) != 1 {
+		t.Fatalf("%d sections, want 1", len(message.Attachments))
+	}
+	section := message.Attachments[0]
+	if section.Title != "app / BackOff" || section.Text != "3 events on 2 objects in the last 1h0m0s" {
+		t.Errorf("got section %q: %q", section.Title, section.Text)
+	}
+}
+
+func TestDigestSectionsPerCluster(t *testing.T) {
+	withConfig(t, &Config{ClusterContexts: []string{"east", "west"}})
+	d := newDigest("namespace")
+	for _, cluster := range []string{"east", "west", "west"} {
+		event := testEvent("app", "Pod", "web-1", "BackOff", "")
+		event.ClusterName = cluster
+		d.add(event)
+	}
+	var titles []string
+	for _, attachment := range d.message(d.take(), time.Hour).Attachments {
+		titles = append(titles, attachment.Title)
+	}
+	if want := []string{"west / app", "east / app"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("sections %q, want %q", titles, want)
+	}
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	appsv1beta1 "k8s.io/client-go/pkg/apis/apps/v1beta1"
+	batchv1 "k8s.io/client-go/pkg/apis/batch/v1"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/pkg/fields"
+	"k8s.io/client-go/pkg/util/flowcontrol"
+)
+
+// enrichment holds context looked up from the API about an event's
+// involved object that the event itself does not carry.
+type enrichment struct {
+	ControllerKind string
+	ControllerName string
+	OOMKilled      *oomKill
+	FiringAlerts   []string
+	RecentEvents   []string
+	QOSClass       string
+	Resources      []string
+	Capacity       []string
+	Job            string
+	CronJob        string
+
+	// PreviousCount is the count the event was last notified with, 0 when
+	// it is notified for the first time.
+	PreviousCount int32
+
+	// Skipped is set when a lookup exceeded ENRICHMENT_TIMEOUT or
+	// ENRICHMENT_QPS and the notification goes out without its result.
+	Skipped bool
+}
+
+// oomKill describes a container terminated for exceeding its memory limit.
+type oomKill struct {
+	Container   string
+	MemoryLimit string
+	FinishedAt  time.Time
+}
+
+func enrichEvent(clientset *kubernetes.Clientset, event *v1.Event) *enrichment {
+	extra := &enrichment{}
+	if cfg.ShowController {
+		kind, name, err := resolveController(clientset, event.InvolvedObject.Namespace, event.InvolvedObject.Kind, event.InvolvedObject.Name)
+		if enrichmentSkipped(err) {
+			extra.Skipped = true
+		} else if err != nil {
+			log.Printf("Unable to resolve the controller of %s %s/%s: %v", event.InvolvedObject.Kind, event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+		} else {
+			extra.ControllerKind, extra.ControllerName = kind, name
+		}
+	}
+	if (cfg.DetectOOM || cfg.ShowPodResources) && event.InvolvedObject.Kind == "Pod" {
+		pod, err := lookupPod(clientset, event.InvolvedObject.Namespace, event.InvolvedObject.Name)
+		if enrichmentSkipped(err) {
+			extra.Skipped = true
+		} else if err != nil {
+			log.Printf("Unable to look up pod %s/%s: %v", event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+		} else {
+			if cfg.DetectOOM {
+				extra.OOMKilled = findOOMKill(pod)
+			}
+			if cfg.ShowPodResources {
+				extra.QOSClass = qosClass(pod)
+				extra.Resources = containerResources(pod, extra.OOMKilled)
+			}
+		}
+	}
+	if cfg.JobAware {
+		job, cronJob, err := jobOwners(clientset, event)
+		if enrichmentSkipped(err) {
+			extra.Skipped = true
+		} else if err != nil {
+			log.Printf("Unable to resolve the Job of %s %s/%s: %v", event.InvolvedObject.Kind, event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+		} else {
+			extra.Job, extra.CronJob = job, cronJob
+		}
+	}
+	if cfg.PrometheusURL != "" {
+		alerts, err := firingAlerts(event)
+		if enrichmentSkipped(err) {
+			extra.Skipped = true
+		} else if err != nil {
+			log.Printf("Unable to fetch alerts from Prometheus: %v", err)
+		} else {
+			extra.FiringAlerts = alerts
+		}
+	}
+	if cfg.ShowClusterCapacity && canonicalReason(event.Reason) == canonicalReason("FailedScheduling") {
+		capacity, err := schedulingCapacity(clientset, event)
+		if enrichmentSkipped(err) {
+			extra.Skipped = true
+		} else if err != nil {
+			log.Printf("Unable to sum the cluster capacity: %v", err)
+		} else {
+			extra.Capacity = capacity
+		}
+	}
+	if cfg.ShowRecentEvents {
+		recent, err := recentEvents(clientset, event)
+		if enrichmentSkipped(err) {
+			extra.Skipped = true
+		} else if err != nil {
+			log.Printf("Unable to list the events of %s %s/%s: %v", event.InvolvedObject.Kind, event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+		} else {
+			extra.RecentEvents = recent
+		}
+	}
+	return extra
+}
+
+// recentEvents lists the other events of the event's involved object within
+// RECENT_EVENTS_WINDOW, most recent first, condensed to one line each.
+func recentEvents(clientset *kubernetes.Clientset, event *v1.Event) ([]string, error) {
+	object := event.InvolvedObject
+	list, err := objectCache.fetch(clusterName(clientset)+"/Events/"+object.Namespace+"/"+object.Kind+"/"+object.Name, withEnrichmentTimeout(throttled(func() (interface{}, error) {
+		selector := fields.Set{"involvedObject.kind": object.Kind, "involvedObject.name": object.Name}.AsSelector().String()
+		return clientset.CoreV1().Events(object.Namespace).List(v1.ListOptions{FieldSelector: selector})
+	})))
+	if err != nil {
+		return nil, err
+	}
+	// Sort a copy, the list is shared through the cache.
+	events := append([]v1.Event(nil), list.(*v1.EventList).Items...)
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].LastTimestamp.After(events[j].LastTimestamp.Time)
+	})
+	var lines []string
+	for _, e := range events {
+		if e.UID == event.UID || time.Since(e.LastTimestamp.Time) > cfg.RecentEventsWindow {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", e.LastTimestamp.Format("15:04:05"), e.Reason, e.Message))
+		if len(lines) == cfg.RecentEventsLimit {
+			break
+		}
+	}
+	return lines, nil
+}
+
+// findOOMKill returns the most recent OOM kill among the pod's containers,
+// whether it is the current or the last termination state.
+func findOOMKill(pod *v1.Pod) *oomKill {
+	var found *oomKill
+	for _, status := range pod.Status.ContainerStatuses {
+		for _, state := range []v1.ContainerState{status.State, status.LastTerminationState} {
+			terminated := state.Terminated
+			if terminated == nil || terminated.Reason != "OOMKilled" {
+				continue
+			}
+			if found != nil && !terminated.FinishedAt.Time.After(found.FinishedAt) {
+				continue
+			}
+			found = &oomKill{
+				Container:   status.Name,
+				MemoryLimit: "none",
+				FinishedAt:  terminated.FinishedAt.Time,
+			}
+			for _, container := range pod.Spec.Containers {
+				if limit, ok := container.Resources.Limits[v1.ResourceMemory]; ok && container.Name == status.Name {
+					found.MemoryLimit = limit.String()
+				}
+			}
+		}
+	}
+	return found
+}
+
+// qosClass computes the pod's QoS class as the kubelet does, the pod
+// status of this API version not reporting it: BestEffort without any
+// request or limit, Guaranteed when every container has CPU and memory
+// limits equal to its requests, Burstable otherwise.
+func qosClass(pod *v1.Pod) string {
+	bestEffort, guaranteed := true, true
+	for _, container := range pod.Spec.Containers {
+		if len(container.Resources.Requests) > 0 || len(container.Resources.Limits) > 0 {
+			bestEffort = false
+		}
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			limit, ok := container.Resources.Limits[name]
+			if !ok {
+				guaranteed = false
+				continue
+			}
+			// Requests default to the limits when unset.
+			if request, ok := container.Resources.Requests[name]; ok && request.Cmp(limit) != 0 {
+				guaranteed = false
+			}
+		}
+	}
+	switch {
+	case bestEffort:
+		return "BestEffort"
+	case guaranteed:
+		return "Guaranteed"
+	}
+	return "Burstable"
+}
+
+// containerResources describes the requests and limits of the container
+// that was OOM killed, or of every container when none was.
+func containerResources(pod *v1.Pod, oom *oomKill) []string {
+	var lines []string
+	for _, container := range pod.Spec.Containers {
+		if oom != nil && container.Name != oom.Container {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: requests %s, limits %s", container.Name, formatResources(container.Resources.Requests), formatResources(container.Resources.Limits)))
+	}
+	return lines
+}
+
+func formatResources(resources v1.ResourceList) string {
+	if len(resources) == 0 {
+		return "none"
+	}
+	var parts []string
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		if quantity, ok := resources[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, quantity.String()))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, " ")
+}
+
+// resolveController follows controller owner references up from the given
+// object and returns the top-level workload managing it, e.g. the Deployment
+// behind a Pod's ReplicaSet. Objects without a controller resolve to
+// themselves.
+func resolveController(clientset *kubernetes.Clientset, namespace, kind, name string) (string, string, error) {
+	for {
+		meta, err := objectMeta(clientset, namespace, kind, name)
+		if err != nil {
+			return "", "", err
+		}
+		if meta == nil {
+			return kind, name, nil
+		}
+		if dc, ok := meta.Annotations["openshift.io/deployment-config.name"]; ok && kind == "ReplicationController" {
+			return "DeploymentConfig", dc, nil
+		}
+		owner := controllerOf(meta)
+		if owner == nil {
+			return kind, name, nil
+		}
+		kind, name = owner.Kind, owner.Name
+	}
+}
+
+func controllerOf(meta *v1.ObjectMeta) *v1.OwnerReference {
+	for i, owner := range meta.OwnerReferences {
+		if owner.Controller != nil && *owner.Controller {
+			return &meta.OwnerReferences[i]
+		}
+	}
+	return nil
+}
+
+var objectCache *lruCache
+
+var objectCacheEntries = newGaugeFunc("enrichment_cache_entries", "Objects currently held in the enrichment cache.", func() float64 {
+	if objectCache == nil {
+		return 0
+	}
+	return float64(objectCache.len())
+})
+
+// lookupObject fetches a workload object or namespace through the shared object cache,
+// returning nil for kinds it does not know about.
+func lookupObject(clientset *kubernetes.Clientset, namespace, kind, name string) (interface{}, error) {
+	return objectCache.fetch(clusterName(clientset)+"/"+kind+"/"+namespace+"/"+name, withEnrichmentTimeout(throttled(func() (interface{}, error) {
+		switch kind {
+		case "Pod":
+			return clientset.CoreV1().Pods(namespace).Get(name)
+		case "ReplicaSet":
+			return clientset.ExtensionsV1beta1().ReplicaSets(namespace).Get(name)
+		case "ReplicationController":
+			return clientset.CoreV1().ReplicationControllers(namespace).Get(name)
+		case "Job":
+			return clientset.BatchV1().Jobs(namespace).Get(name)
+		case "Deployment":
+			return clientset.ExtensionsV1beta1().Deployments(namespace).Get(name)
+		case "DaemonSet":
+			return clientset.ExtensionsV1beta1().DaemonSets(namespace).Get(name)
+		case "StatefulSet":
+			return clientset.AppsV1beta1().StatefulSets(namespace).Get(name)
+		case "Namespace":
+			return clientset.CoreV1().Namespaces().Get(name)
+		}
+		return nil, nil
+	})))
+}
+
+// errEnrichmentTimeout is returned by lookups that took longer than
+// ENRICHMENT_TIMEOUT.
+var errEnrichmentTimeout = errors.New("enrichment lookup timed out")
+
+// errEnrichmentThrottled is returned by lookups beyond ENRICHMENT_QPS.
+var errEnrichmentThrottled = errors.New("enrichment lookup throttled")
+
+// enrichmentSkipped reports whether a lookup was given up on to spare the
+// API server, in which case the notification is sent without its result.
+func enrichmentSkipped(err error) bool {
+	return err == errEnrichmentTimeout || err == errEnrichmentThrottled
+}
+
+var (
+	// enrichmentLimiter is a token bucket of ENRICHMENT_QPS API lookups a
+	// second, nil without a limit.
+	enrichmentLimiter flowcontrol.RateLimiter
+
+	enrichmentThrottled = newCounterVec("enrichment_throttled_total", "API lookups skipped as they exceeded ENRICHMENT_QPS.")
+)
+
+// throttled skips load when the API lookups exceed ENRICHMENT_QPS. Only
+// cache misses reach it, so cached lookups are never throttled.
+func throttled(load func() (interface{}, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		if enrichmentLimiter != nil && !enrichmentLimiter.TryAccept() {
+			enrichmentThrottled.inc()
+			return nil, errEnrichmentThrottled
+		}
+		return load()
+	}
+}
+
+// withEnrichmentTimeout bounds load by ENRICHMENT_TIMEOUT. The client-go
+// calls take no context, so a lookup that times out is abandoned rather than
+// cancelled and its result discarded.
+func withEnrichmentTimeout(load func() (interface{}, error)) func() (interface{}, error) {
+	if cfg.EnrichmentTimeout <= 0 {
+		return load
+	}
+	return func() (interface{}, error) {
+		type result struct {
+			value interface{}
+			err   error
+		}
+		done := make(chan result, 1)
+		go func() {
+			value, err := load()
+			done <- result{value, err}
+		}()
+		select {
+		case r := <-done:
+			return r.value, r.err
+		case <-time.After(cfg.EnrichmentTimeout):
+			return nil, errEnrichmentTimeout
+		}
+	}
+}
+
+func lookupPod(clientset *kubernetes.Clientset, namespace, name string) (*v1.Pod, error) {
+	pod, err := lookupObject(clientset, namespace, "Pod", name)
+	if err != nil {
+		return nil, err
+	}
+	return pod.(*v1.Pod), nil
+}
+
+// objectMeta fetches the metadata of the workload kinds, returning nil for
+// any other kind.
+func objectMeta(clientset *kubernetes.Clientset, namespace, kind, name string) (*v1.ObjectMeta, error) {
+	object, err := lookupObject(clientset, namespace, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	switch o := object.(type) {
+	case *v1.Pod:
+		return &o.ObjectMeta, nil
+	case *v1beta1.ReplicaSet:
+		return &o.ObjectMeta, nil
+	case *v1.ReplicationController:
+		return &o.ObjectMeta, nil
+	case *batchv1.Job:
+		return &o.ObjectMeta, nil
+	case *v1beta1.Deployment:
+		return &o.ObjectMeta, nil
+	case *v1beta1.DaemonSet:
+		return &o.ObjectMeta, nil
+	case *appsv1beta1.StatefulSet:
+		return &o.ObjectMeta, nil
+	}
+	return nil, nil
+}
+
+// inStartupGrace reports whether the event's involved object was younger
+// than STARTUP_WARNING_GRACE when the event occurred, as freshly created
+// pods routinely emit transient warnings (e.g. FailedMount) while starting.
+func inStartupGrace(clientset *kubernetes.Clientset, event *v1.Event) bool {
+	meta, err := objectMeta(clientset, event.InvolvedObject.Namespace, event.InvolvedObject.Kind, event.InvolvedObject.Name)
+	if err != nil || meta == nil {
+		return false
+	}
+	occurred := event.LastTimestamp.Time
+	if occurred.IsZero() {
+		occurred = event.FirstTimestamp.Time
+	}
+	return occurred.Sub(meta.CreationTimestamp.Time) < cfg.StartupWarningGrace
+}
+
+// revisionLabels are the labels controllers set on their pods to the hash
+// of the pod template they were created from.
+var revisionLabels = []string{"pod-template-hash", "controller-revision-hash"}
+
+// generationOf identifies the incarnation of the event's involved object:
+// for workloads the generation, which changes on every rollout, and for
+// their pods the revision of the pod template, shared by the pods of a
+// rollout. Other objects are identified by their UID, which changes
+// whenever they are recreated.
+func generationOf(clientset *kubernetes.Clientset, event *v1.Event) string {
+	generation := string(event.InvolvedObject.UID)
+	switch event.InvolvedObject.Kind {
+	case "Deployment", "DaemonSet", "StatefulSet":
+		meta, err := objectMeta(clientset, event.InvolvedObject.Namespace, event.InvolvedObject.Kind, event.InvolvedObject.Name)
+		if err != nil {
+			log.Printf("Unable to look up the generation of %s %s/%s: %v", event.InvolvedObject.Kind, event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+		} else if meta != nil {
+			generation += "@" + strconv.FormatInt(meta.Generation, 10)
+		}
+	case "Pod":
+		meta, err := objectMeta(clientset, event.InvolvedObject.Namespace, event.InvolvedObject.Kind, event.InvolvedObject.Name)
+		if err != nil {
+			log.Printf("Unable to look up the revision of Pod %s/%s: %v", event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+			break
+		}
+		for _, label := range revisionLabels {
+			if hash := meta.Labels[label]; hash != "" {
+				return label + "=" + hash
+			}
+		}
+	}
+	return generation
+}
+
+// namespaceTerminating reports whether the event's namespace is being
+// deleted, in which case its objects' warnings are expected teardown noise.
+func namespaceTerminating(clientset *kubernetes.Clientset, event *v1.Event) bool {
+	object, err := lookupObject(clientset, "", "Namespace", event.InvolvedObject.Namespace)
+	if err != nil {
+		slog.Debug("Unable to look up namespace", "namespace", event.InvolvedObject.Namespace, "error", err)
+		return false
+	}
+	return object.(*v1.Namespace).Status.Phase == v1.NamespaceTerminating
+}
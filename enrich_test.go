@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/types"
+)
+
+func TestCapacityOfNormalizedFailedScheduling(t *testing.T) {
+	saved := capacityCache
+	capacityCache = newLRUCache("capacity", time.Hour, 16)
+	t.Cleanup(func() { capacityCache = saved })
+	capacityCache.set("", &clusterCapacity{
+		allocatable: map[v1.ResourceName]int64{v1.ResourceCPU: 4000},
+		requested:   map[v1.ResourceName]int64{v1.ResourceCPU: 3000},
+	})
+	want := []string{"cpu 75% requested (3.0 cores of 4.0 cores)"}
+
+	for _, test := range []struct {
+		normalize bool
+		reason    string
+		want      []string
+	}{
+		{false, "FailedScheduling", want},
+		{true, "FailedScheduling", want},
+		{true, "failedScheduling", want},
+		{true, " FailedScheduling ", want},
+		{false, "failedScheduling", nil},
+	} {
+		withConfig(t, &Config{ShowClusterCapacity: true, NormalizeReasons: test.normalize})
+		event := testEvent("app", "Pod", "web-1", test.reason, "0/3 nodes are available: 3 Insufficient cpu.")
+		if got := enrichEvent(nil, event).Capacity; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("reason %q, normalized %v: capacity %q, want %q", test.reason, test.normalize, got, test.want)
+		}
+	}
+}
+
+func TestPodsOfOneRolloutShareAGeneration(t *testing.T) {
+	withConfig(t, &Config{})
+	revision := func(hash string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: v1.ObjectMeta{Labels: map[string]string{"pod-template-hash": hash}, OwnerReferences: controlledBy("ReplicaSet", "web-"+hash)}}
+	}
+	withObjects(t, map[string]interface{}{
+		"/Pod/app/web-7d9f-a": revision("7d9f"),
+		"/Pod/app/web-7d9f-b": revision("7d9f"),
+		"/Pod/app/web-5c4b-a": revision("5c4b"),
+	})
+	generation := func(name string) string {
+		event := testEvent("app", "Pod", name, "BackOff", "Back-off restarting failed container")
+		event.InvolvedObject.UID = types.UID("uid-" + name)
+		return generationOf(nil, event)
+	}
+
+	if a, b := generation("web-7d9f-a"), generation("web-7d9f-b"); a != b {
+		t.Errorf("pods of one ReplicaSet have the generations %q and %q", a, b)
+	}
+	if generation("web-7d9f-a") == generation("web-5c4b-a") {
+		t.Error("pods of two rollouts share a generation")
+	}
+}
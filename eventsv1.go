@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// The client-go version pinned by glide predates the events.k8s.io group,
+// so its events are watched through the raw REST client and decoded into
+// the fields below, then mapped onto the core/v1 Event used internally.
+
+type eventsV1Series struct {
+	Count            int32            `json:"count"`
+	LastObservedTime unversioned.Time `json:"lastObservedTime"`
+}
+
+type eventsV1Event struct {
+	Metadata                 v1.ObjectMeta      `json:"metadata"`
+	EventTime                unversioned.Time   `json:"eventTime"`
+	Series                   *eventsV1Series    `json:"series"`
+	ReportingController      string             `json:"reportingController"`
+	Action                   string             `json:"action"`
+	Reason                   string             `json:"reason"`
+	Regarding                v1.ObjectReference `json:"regarding"`
+	Note                     string             `json:"note"`
+	Type                     string             `json:"type"`
+	DeprecatedSource         v1.EventSource     `json:"deprecatedSource"`
+	DeprecatedFirstTimestamp unversioned.Time   `json:"deprecatedFirstTimestamp"`
+	DeprecatedLastTimestamp  unversioned.Time   `json:"deprecatedLastTimestamp"`
+	DeprecatedCount          int32              `json:"deprecatedCount"`
+}
+
+// actionAnnotation carries the Action of an events.k8s.io/v1 event, which
+// core/v1 has no field for.
+const actionAnnotation = "events.k8s.io/action"
+
+// toCoreEvent maps the event onto core/v1: Note becomes the message,
+// Regarding the involved object, Series the count and last occurrence and
+// Action an annotation.
+func (e *eventsV1Event) toCoreEvent() *v1.Event {
+	event := &v1.Event{
+		ObjectMeta:     e.Metadata,
+		InvolvedObject: e.Regarding,
+		Reason:         e.Reason,
+		Message:        e.Note,
+		Source:         e.DeprecatedSource,
+		FirstTimestamp: e.DeprecatedFirstTimestamp,
+		LastTimestamp:  e.DeprecatedLastTimestamp,
+		Count:          e.DeprecatedCount,
+		Type:           e.Type,
+	}
+	if e.ReportingController != "" {
+		event.Source.Component = e.ReportingController
+	}
+	if e.Action != "" {
+		if event.Annotations == nil {
+			event.Annotations = map[string]string{}
+		}
+		event.Annotations[actionAnnotation] = e.Action
+	}
+	if event.FirstTimestamp.IsZero() {
+		event.FirstTimestamp = e.EventTime
+	}
+	if event.LastTimestamp.IsZero() {
+		event.LastTimestamp = e.EventTime
+	}
+	if e.Series != nil {
+		event.Count = e.Series.Count
+		event.LastTimestamp = e.Series.LastObservedTime
+	}
+	if event.Count == 0 {
+		event.Count = 1
+	}
+	return event
+}
+
+type eventsV1WatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// useEventsV1 decides from EVENTS_API whether to watch events.k8s.io/v1,
+// detecting whether the server serves it in "auto" mode.
+func useEventsV1(clientset *kubernetes.Clientset) bool {
+	switch cfg.EventsAPI {
+	case "events":
+		return true
+	case "auto":
+		_, err := clientset.Discovery().ServerResourcesForGroupVersion("events.k8s.io/v1")
+		return err == nil
+	}
+	return false
+}
+
+// streamEventsV1 watches events.k8s.io/v1 and sends the mapped events on
+// the returned channel until the watch ends or is stopped.
+func streamEventsV1(clientset *kubernetes.Clientset, fieldSelector, resourceVersion string) (<-chan *v1.Event, func(), error) {
+	request := clientset.CoreV1().RESTClient().Get().AbsPath("/apis/events.k8s.io/v1/events").Param("watch", "true")
+	if fieldSelector != "" {
+		request = request.Param("fieldSelector", fieldSelector)
+	}
+	if resourceVersion != "" {
+		request = request.Param("resourceVersion", resourceVersion)
+	}
+	stream, err := request.Stream()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events := make(chan *v1.Event)
+	go func() {
+		defer close(events)
+		defer stream.Close()
+		decoder := json.NewDecoder(stream)
+		for {
+			var watchEvent eventsV1WatchEvent
+			if err := decoder.Decode(&watchEvent); err != nil {
+				if err != io.EOF {
+					log.Printf("events.k8s.io/v1 watch ended: %v", err)
+				}
+				return
+			}
+			if watchEvent.Type == "ERROR" {
+				log.Printf("events.k8s.io/v1 watch failed: %s", watchEvent.Object)
+				return
+			}
+			var event eventsV1Event
+			if err := json.Unmarshal(watchEvent.Object, &event); err != nil {
+				log.Printf("Skipping undecodable events.k8s.io/v1 event: %v", err)
+				continue
+			}
+			events <- event.toCoreEvent()
+		}
+	}()
+	return events, func() { stream.Close() }, nil
+}
+
+// streamCoreEvents watches core/v1 events and sends them on the returned
+// channel until the watch ends or is stopped.
+func streamCoreEvents(clientset *kubernetes.Clientset, fieldSelector, resourceVersion string) (<-chan *v1.Event, func(), error) {
+	watcher, err := clientset.CoreV1().Events("").Watch(v1.ListOptions{FieldSelector: fieldSelector, ResourceVersion: resourceVersion})
+	if err != nil {
+		return nil, nil, err
+	}
+	events := make(chan *v1.Event)
+	go func() {
+		defer close(events)
+		for watchEvent := range watcher.ResultChan() {
+			event, ok := watchEvent.Object.(*v1.Event)
+			if !ok {
+				log.Printf("core/v1 watch failed: %s", fmt.Sprint(watchEvent.Object))
+				watcher.Stop()
+				return
+			}
+			events <- event
+		}
+	}()
+	return events, watcher.Stop, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// testTimestamps returns first, last and event times an hour apart.
+func testTimestamps() (first, last, eventTime unversioned.Time) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	return unversioned.NewTime(start), unversioned.NewTime(start.Add(time.Hour)), unversioned.NewTime(start.Add(2 * time.Hour))
+}
+
+func TestOccurredAt(t *testing.T) {
+	first, last, _ := testTimestamps()
+	for _, test := range []struct {
+		name        string
+		first, last unversioned.Time
+		want        time.Time
+	}{
+		{"both timestamps", first, last, first.Time},
+		{"first timestamp only", first, unversioned.Time{}, first.Time},
+		{"last timestamp only", unversioned.Time{}, last, last.Time},
+		{"no timestamps", unversioned.Time{}, unversioned.Time{}, time.Time{}},
+	} {
+		event := &v1.Event{FirstTimestamp: test.first, LastTimestamp: test.last}
+		if got := occurredAt(event); !got.Equal(test.want) {
+			t.Errorf("%s: occurred at %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEventsV1Timestamps(t *testing.T) {
+	first, last, eventTime := testTimestamps()
+	for _, test := range []struct {
+		name                string
+		event               eventsV1Event
+		wantFirst, wantLast time.Time
+	}{
+		{"deprecated timestamps",
+			eventsV1Event{DeprecatedFirstTimestamp: first, DeprecatedLastTimestamp: last, EventTime: eventTime},
+			first.Time, last.Time},
+		{"event time only",
+			eventsV1Event{EventTime: eventTime},
+			eventTime.Time, eventTime.Time},
+		{"deprecated last timestamp and event time",
+			eventsV1Event{DeprecatedLastTimestamp: last, EventTime: eventTime},
+			eventTime.Time, last.Time},
+		{"series",
+			eventsV1Event{EventTime: eventTime, Series: &eventsV1Series{Count: 4, LastObservedTime: unversioned.NewTime(eventTime.Add(time.Hour))}},
+			eventTime.Time, eventTime.Add(time.Hour)},
+	} {
+		event := test.event.toCoreEvent()
+		if !event.FirstTimestamp.Time.Equal(test.wantFirst) || !event.LastTimestamp.Time.Equal(test.wantLast) {
+			t.Errorf("%s: got %v to %v, want %v to %v", test.name, event.FirstTimestamp, event.LastTimestamp, test.wantFirst, test.wantLast)
+		}
+		if !occurredAt(event).Equal(test.wantFirst) {
+			t.Errorf("%s: occurred at %v, want %v", test.name, occurredAt(event), test.wantFirst)
+		}
+	}
+}
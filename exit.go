@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Errors fall in two categories. Recoverable ones, such as a failed
+// notification, an enrichment lookup or a dropped watch, are logged and
+// retried where it makes sense, and never stop the notifier. Unrecoverable
+// ones stop it with fatal, with one of the exit codes below so the cause
+// shows in the pod's last state without digging through its logs.
+const (
+	// exitConfig is returned when the configuration is invalid.
+	exitConfig = 2
+	// exitStartup is returned when something needed to run is unusable,
+	// e.g. the service account credentials or the outbox directory.
+	exitStartup = 3
+	// exitWatch is returned when the event watch fails and
+	// EXIT_ON_WATCH_FAILURE asks to restart rather than retry.
+	exitWatch = 4
+)
+
+// fatal logs the error and exits with code, without the stack trace a
+// panic would print.
+func fatal(code int, format string, args ...interface{}) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(code)
+}
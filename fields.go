@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// fieldBuilders render the optional attachment fields by key. A builder
+// returns no fields when its information is disabled or unavailable.
+var fieldBuilders = map[string]func(event *v1.Event, extra *enrichment) []SlackField{
+	"cluster": func(event *v1.Event, extra *enrichment) []SlackField {
+		if event.ClusterName == "" {
+			return nil
+		}
+		return []SlackField{{Title: "Cluster", Value: event.ClusterName, Short: true}}
+	},
+	"reason": func(event *v1.Event, extra *enrichment) []SlackField {
+		return []SlackField{{Title: "Reason", Value: event.Reason, Short: true}}
+	},
+	"action": func(event *v1.Event, extra *enrichment) []SlackField {
+		action := strings.TrimSpace(event.Annotations[actionAnnotation])
+		if !cfg.ShowEventAction || action == "" {
+			return nil
+		}
+		return []SlackField{{Title: "Action", Value: action, Short: true}}
+	},
+	"kind": func(event *v1.Event, extra *enrichment) []SlackField {
+		kind := event.InvolvedObject.Kind
+		if kind == "" {
+			kind = cfg.EmptyKind
+		}
+		if kind == "" {
+			return nil
+		}
+		return []SlackField{{Title: "Kind", Value: kind, Short: true}}
+	},
+	"count": func(event *v1.Event, extra *enrichment) []SlackField {
+		if len(cfg.DedupCountBuckets) == 0 || event.Count <= 1 {
+			return nil
+		}
+		return []SlackField{{Title: "Count", Value: fmt.Sprint(event.Count), Short: true}}
+	},
+	"delta": func(event *v1.Event, extra *enrichment) []SlackField {
+		if extra.PreviousCount == 0 {
+			return nil
+		}
+		delta := event.Count - extra.PreviousCount
+		if delta < 0 {
+			// The event was recreated and counts from scratch again.
+			delta = event.Count
+		}
+		return []SlackField{{Title: "Since Last Notification", Value: fmt.Sprintf("+%d", delta), Short: true}}
+	},
+	"oom": func(event *v1.Event, extra *enrichment) []SlackField {
+		if extra.OOMKilled == nil {
+			return nil
+		}
+		return []SlackField{
+			{Title: "Container", Value: extra.OOMKilled.Container, Short: true},
+			{Title: "Memory Limit", Value: extra.OOMKilled.MemoryLimit, Short: true},
+		}
+	},
+	"resources": func(event *v1.Event, extra *enrichment) []SlackField {
+		if extra.QOSClass == "" {
+			return nil
+		}
+		return []SlackField{
+			{Title: "QoS Class", Value: extra.QOSClass, Short: true},
+			{Title: "Resources", Value: strings.Join(extra.Resources, "\n"), Short: false},
+		}
+	},
+	"capacity": func(event *v1.Event, extra *enrichment) []SlackField {
+		if len(extra.Capacity) == 0 {
+			return nil
+		}
+		return []SlackField{{Title: "Cluster Capacity", Value: strings.Join(extra.Capacity, "\n"), Short: false}}
+	},
+	"controller": func(event *v1.Event, extra *enrichment) []SlackField {
+		if extra.ControllerKind == "" {
+			return nil
+		}
+		return []SlackField{{Title: "Controller", Value: extra.ControllerKind, Short: true}}
+	},
+	"job": func(event *v1.Event, extra *enrichment) []SlackField {
+		var fields []SlackField
+		if extra.Job != "" && event.InvolvedObject.Kind != "Job" {
+			fields = append(fields, SlackField{Title: "Job", Value: extra.Job, Short: true})
+		}
+		if extra.CronJob != "" && event.InvolvedObject.Kind != "CronJob" {
+			fields = append(fields, SlackField{Title: "CronJob", Value: extra.CronJob, Short: true})
+		}
+		return fields
+	},
+	"alerts": func(event *v1.Event, extra *enrichment) []SlackField {
+		if len(extra.FiringAlerts) == 0 {
+			return nil
+		}
+		return []SlackField{{Title: "Firing Alerts", Value: strings.Join(extra.FiringAlerts, ", "), Short: false}}
+	},
+	"recent": func(event *v1.Event, extra *enrichment) []SlackField {
+		if len(extra.RecentEvents) == 0 {
+			return nil
+		}
+		return []SlackField{{Title: "Recent Events", Value: strings.Join(extra.RecentEvents, "\n"), Short: false}}
+	},
+	"parsed": func(event *v1.Event, extra *enrichment) []SlackField {
+		if !cfg.ParseMessageFields {
+			return nil
+		}
+		var fields []SlackField
+		for _, pair := range parseMessageFields(event.Message) {
+			fields = append(fields, SlackField{Title: pair[0], Value: pair[1], Short: len(pair[1]) <= 40})
+		}
+		return fields
+	},
+	"release": func(event *v1.Event, extra *enrichment) []SlackField {
+		if cfg.ReleaseID == "" {
+			return nil
+		}
+		return []SlackField{{Title: "Release", Value: cfg.ReleaseID, Short: true}}
+	},
+}
+
+var defaultFieldOrder = []string{"cluster", "reason", "action", "kind", "count", "delta", "oom", "resources", "capacity", "controller", "job", "alerts", "recent", "parsed", "release"}
+
+// fieldSpec is a FIELD_ORDER entry: the field, and its priority when the
+// message has to shed fields.
+type fieldSpec struct {
+	Key      string
+	Priority int
+}
+
+// fieldPriorityRequired is the priority from which fields are never dropped.
+const fieldPriorityRequired = 100
+
+// defaultFieldPriorities rank the fields by how much context is lost
+// without them. Fields not listed have priority 0. The Job or CronJob of a
+// pod, only shown for the pods of jobs, names what failed better than the
+// pod does.
+var defaultFieldPriorities = map[string]int{
+	"reason":     fieldPriorityRequired,
+	"job":        95,
+	"kind":       90,
+	"cluster":    80,
+	"oom":        70,
+	"count":      60,
+	"delta":      50,
+	"controller": 50,
+	"action":     40,
+	"alerts":     40,
+	"capacity":   30,
+	"resources":  20,
+	"release":    10,
+}
+
+// parseFieldOrder parses FIELD_ORDER entries, each a field optionally
+// followed by its priority, e.g. "reason,kind,recent:95".
+func parseFieldOrder(entries []string) ([]fieldSpec, error) {
+	specs := make([]fieldSpec, len(entries))
+	for i, entry := range entries {
+		key, priority := entry, ""
+		if j := strings.Index(entry, ":"); j >= 0 {
+			key, priority = strings.TrimSpace(entry[:j]), strings.TrimSpace(entry[j+1:])
+		}
+		specs[i] = fieldSpec{Key: key, Priority: defaultFieldPriorities[key]}
+		if priority != "" {
+			var err error
+			if specs[i].Priority, err = strconv.Atoi(priority); err != nil {
+				return nil, fmt.Errorf("invalid priority in %q", entry)
+			}
+		}
+	}
+	return specs, nil
+}
+
+var fieldsDropped = newCounterVec("message_fields_dropped_total", "Attachment fields dropped to keep messages within SLACK_MAX_FIELDS and SLACK_FIELDS_MAX_LENGTH.", "field")
+
+var keyValue = regexp.MustCompile(`([A-Za-z_][\w.-]*)=("[^"]*"|[^\s,;]+)`)
+
+// parseMessageFields extracts the structured data some controllers embed in
+// event messages: the members of a message that is a JSON object, or its
+// key=value pairs when it has at least two. Messages matching neither are
+// left as text and yield nothing.
+func parseMessageFields(message string) [][2]string {
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(message)), &object); err == nil {
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		pairs := make([][2]string, len(keys))
+		for i, key := range keys {
+			pairs[i] = [2]string{key, fmt.Sprint(object[key])}
+		}
+		return pairs
+	}
+
+	matches := keyValue.FindAllStringSubmatch(message, -1)
+	if len(matches) < 2 {
+		return nil
+	}
+	pairs := make([][2]string, len(matches))
+	for i, match := range matches {
+		pairs[i] = [2]string{match[1], strings.Trim(match[2], `"`)}
+	}
+	return pairs
+}
+
+// slackFields renders the fields in FIELD_ORDER, skipping unknown keys.
+// Fields left out of a configured order are not shown. Beyond
+// SLACK_MAX_FIELDS fields or SLACK_FIELDS_MAX_LENGTH characters, the fields
+// of the lowest priority are dropped first, the last in FIELD_ORDER among
+// equal priorities. Fields of priority 100 or more are always kept.
+func slackFields(event *v1.Event, extra *enrichment) []SlackField {
+	built := make([][]SlackField, len(cfg.FieldOrder))
+	count, length := 0, 0
+	for i, spec := range cfg.FieldOrder {
+		if build, ok := fieldBuilders[spec.Key]; ok {
+			built[i] = build(event, extra)
+			count += len(built[i])
+			length += fieldsLength(built[i])
+		}
+	}
+
+	// Drop candidates, from the first to drop to the last.
+	var candidates []int
+	for i, spec := range cfg.FieldOrder {
+		if len(built[i]) > 0 && spec.Priority < fieldPriorityRequired {
+			candidates = append(candidates, i)
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		pa, pb := cfg.FieldOrder[candidates[a]].Priority, cfg.FieldOrder[candidates[b]].Priority
+		return pa < pb || (pa == pb && candidates[a] > candidates[b])
+	})
+	for _, i := range candidates {
+		if (cfg.SlackMaxFields <= 0 || count <= cfg.SlackMaxFields) && (cfg.SlackFieldsMaxLength <= 0 || length <= cfg.SlackFieldsMaxLength) {
+			break
+		}
+		count -= len(built[i])
+		length -= fieldsLength(built[i])
+		fieldsDropped.inc(cfg.FieldOrder[i].Key)
+		built[i] = nil
+	}
+
+	var fields []SlackField
+	for _, group := range built {
+		fields = append(fields, group...)
+	}
+	return fields
+}
+
+func fieldsLength(fields []SlackField) int {
+	length := 0
+	for _, field := range fields {
+		length += len(field.Title) + len(field.Value)
+	}
+	return length
+}
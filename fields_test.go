@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestJobFieldOutlastsKindAndCount(t *testing.T) {
+	order, err := parseFieldOrder(defaultFieldOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, &Config{FieldOrder: order, SlackMaxFields: 2})
+	event := testEvent("app", "Pod", "backup-1-x7k2p", "BackOff", "Back-off restarting failed container")
+	event.Count = 4
+
+	fields := slackFields(event, &enrichment{Job: "backup-1"})
+	var titles []string
+	for _, field := range fields {
+		titles = append(titles, field.Title)
+	}
+	if len(fields) != 2 || fields[1].Title != "Job" {
+		t.Errorf("kept fields %v, want the reason and the Job", titles)
+	}
+}
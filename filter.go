@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// canonicalReason is the form reasons are compared in. With
+// NORMALIZE_REASONS, the default, reasons differing only in case or
+// surrounding whitespace, as seen across controller versions, are the same.
+func canonicalReason(reason string) string {
+	if !cfg.NormalizeReasons {
+		return reason
+	}
+	return strings.ToLower(strings.TrimSpace(reason))
+}
+
+// typeReasonRule allows or denies events whose type and reason match its
+// glob patterns.
+type typeReasonRule struct {
+	Type   string
+	Reason string
+	Allow  bool
+}
+
+// parseTypeReasonRules parses TYPE_REASON_RULES, a comma separated list of
+// "<type>:<reason>=allow|deny" entries such as "Warning:FailedScheduling=deny".
+func parseTypeReasonRules(value string) ([]typeReasonRule, error) {
+	var rules []typeReasonRule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		match, action := entry, ""
+		if i := strings.LastIndex(entry, "="); i >= 0 {
+			match, action = entry[:i], entry[i+1:]
+		}
+		parts := strings.SplitN(match, ":", 2)
+		if len(parts) != 2 || (action != "allow" && action != "deny") {
+			return nil, fmt.Errorf("expected <type>:<reason>=allow|deny, got %q", entry)
+		}
+		for _, pattern := range parts {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q", pattern)
+			}
+		}
+		rules = append(rules, typeReasonRule{Type: parts[0], Reason: parts[1], Allow: action == "allow"})
+	}
+	return rules, nil
+}
+
+// shouldNotifyTypeReason applies the first TYPE_REASON_RULES entry matching
+// the event type and reason. Without a match only warnings are notified.
+func shouldNotifyTypeReason(eventType, reason string) bool {
+	for _, rule := range cfg.TypeReasonRules {
+		typeMatches, _ := path.Match(rule.Type, eventType)
+		reasonMatches, _ := path.Match(canonicalReason(rule.Reason), canonicalReason(reason))
+		if typeMatches && reasonMatches {
+			return rule.Allow
+		}
+	}
+	return eventType == "Warning"
+}
+
+// watchesOnlyWarnings reports whether no rule can allow anything but
+// warnings, so the watch can keep filtering on the server side.
+func watchesOnlyWarnings() bool {
+	for _, rule := range cfg.TypeReasonRules {
+		if rule.Allow && rule.Type != "Warning" {
+			return false
+		}
+	}
+	return true
+}
+
+// eventComponent is the source component of the events the notifier
+// records about itself.
+const eventComponent = "openshift-slack-notifications"
+
+// selfEvent reports whether the event was recorded by the notifier and has
+// to be ignored, lest notifying it records another one in a loop. Done with
+// SUPPRESS_SELF_EVENTS, and always while EMIT_EVENTS records them.
+func selfEvent(event *v1.Event) bool {
+	return (cfg.SuppressSelfEvents || cfg.EmitEvents != "off") && event.Source.Component == eventComponent
+}
+
+// controllerCacheTTL is how long the controller an object resolves to is
+// reused. Ownership rarely changes, and resolving it takes a lookup per
+// level of owners.
+const controllerCacheTTL = 10 * time.Minute
+
+var controllerCache = newLRUCache("controllers", controllerCacheTTL, 4096)
+
+// matchesControllerKind reports whether the top-level controller of the
+// event's object, e.g. the Deployment of a Pod's ReplicaSet, is of one of
+// the INCLUDE_CONTROLLER_KINDS. Objects without a controller match on their
+// own kind. Events whose controller can't be resolved are let through
+// rather than risk losing them.
+func matchesControllerKind(clientset *kubernetes.Clientset, event *v1.Event) bool {
+	if len(cfg.IncludeControllerKinds) == 0 {
+		return true
+	}
+	object := event.InvolvedObject
+	controller, err := cachedController(clientset, object)
+	if err != nil {
+		log.Printf("Unable to resolve the controller of %s %s/%s, notifying regardless of INCLUDE_CONTROLLER_KINDS: %v", object.Kind, object.Namespace, object.Name, err)
+		return true
+	}
+	for _, kind := range cfg.IncludeControllerKinds {
+		if strings.EqualFold(kind, controller.Kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// cachedController is resolveController through the controller cache,
+// returning the controller's namespace, kind and name.
+func cachedController(clientset *kubernetes.Clientset, object v1.ObjectReference) (v1.ObjectReference, error) {
+	value, err := controllerCache.fetch(clusterName(clientset)+"/"+object.Kind+"/"+object.Namespace+"/"+object.Name, func() (interface{}, error) {
+		kind, name, err := resolveController(clientset, object.Namespace, object.Kind, object.Name)
+		return v1.ObjectReference{Namespace: object.Namespace, Kind: kind, Name: name}, err
+	})
+	if err != nil {
+		return v1.ObjectReference{}, err
+	}
+	return value.(v1.ObjectReference), nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// flapDetector notices objects alternating between failing and recovered,
+// a failure being a Warning notification and a recovery one of the
+// Recovered notifications; other Normal events, such as Pulled or
+// Scheduled, are routine and no change of state. Once an object changes
+// state more than FLAP_THRESHOLD times within FLAP_WINDOW, a single
+// Flapping notification replaces its individual ones until it has not
+// changed state for a whole window, when a summary with the number of
+// changes is sent.
+type flapDetector struct {
+	threshold int
+	window    time.Duration
+
+	mu      sync.Mutex
+	objects map[string]*flapState
+}
+
+type flapState struct {
+	cluster     string
+	object      v1.ObjectReference
+	failing     bool
+	transitions []time.Time
+	lastSeen    time.Time
+
+	flapping bool
+	changes  int // state changes since the object started flapping
+}
+
+var flaps *flapDetector
+
+func newFlapDetector(threshold int, window time.Duration) *flapDetector {
+	return &flapDetector{threshold: threshold, window: window, objects: map[string]*flapState{}}
+}
+
+func flapKey(event *v1.Event) string {
+	return event.ClusterName + "/" + event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name
+}
+
+// held records the state of the event's object and reports whether its
+// notification is to be held back as the object is flapping.
+func (d *flapDetector) held(event *v1.Event) bool {
+	key := flapKey(event)
+	failing := event.Type == "Warning"
+	if !failing && event.Reason != recoveryReason {
+		return false
+	}
+	now := time.Now()
+
+	d.mu.Lock()
+	s, ok := d.objects[key]
+	if !ok {
+		// Only failures start a history, a recovery needs one to recover
+		// from.
+		if failing {
+			d.objects[key] = &flapState{cluster: event.ClusterName, object: event.InvolvedObject, failing: true, lastSeen: now}
+		}
+		d.mu.Unlock()
+		return false
+	}
+	s.lastSeen = now
+	if s.failing != failing {
+		s.failing = failing
+		s.transitions = append(recentTimes(s.transitions, now.Add(-d.window)), now)
+		if s.flapping {
+			s.changes++
+		}
+	}
+	if s.flapping {
+		d.mu.Unlock()
+		return true
+	}
+	if len(s.transitions) <= d.threshold {
+		d.mu.Unlock()
+		return false
+	}
+	s.flapping, s.changes = true, len(s.transitions)
+	changes := s.changes
+	d.mu.Unlock()
+
+	d.notify(flapEvent(event.ClusterName, event.InvolvedObject, "Warning", "Flapping",
+		fmt.Sprintf("Alternated between failing and recovered %d times within %s, further changes are held back until it settles", changes, d.window)))
+	return true
+}
+
+// wouldHold reports whether held would hold the event back, without
+// recording it.
+func (d *flapDetector) wouldHold(event *v1.Event) bool {
+	failing := event.Type == "Warning"
+	if !failing && event.Reason != recoveryReason {
+		return false
+	}
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.objects[flapKey(event)]
+	if !ok {
+		return false
+	}
+	if s.flapping {
+		return true
+	}
+	transitions := len(recentTimes(s.transitions, now.Add(-d.window)))
+	if s.failing != failing {
+		transitions++
+	}
+	return transitions > d.threshold
+}
+
+// recentTimes drops the times before since.
+func recentTimes(times []time.Time, since time.Time) []time.Time {
+	for len(times) > 0 && times[0].Before(since) {
+		times = times[1:]
+	}
+	return times
+}
+
+// run periodically ends the flapping of objects that settled and forgets
+// objects that did not change for a whole window.
+func (d *flapDetector) run() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		var settled []*v1.Event
+
+		d.mu.Lock()
+		for key, s := range d.objects {
+			s.transitions = recentTimes(s.transitions, now.Add(-d.window))
+			if len(s.transitions) > 0 {
+				continue
+			}
+			if s.flapping {
+				state := "recovered"
+				if s.failing {
+					state = "failing"
+				}
+				settled = append(settled, flapEvent(s.cluster, s.object, "Normal", "FlappingStopped",
+					fmt.Sprintf("Settled after %d changes, currently %s", s.changes, state)))
+				s.flapping = false
+			}
+			if now.Sub(s.lastSeen) > d.window {
+				delete(d.objects, key)
+			}
+		}
+		d.mu.Unlock()
+
+		for _, event := range settled {
+			d.notify(event)
+		}
+	}
+}
+
+func (d *flapDetector) notify(event *v1.Event) {
+	if err := notifier.Notify(event, &enrichment{}); err != nil {
+		log.Printf("Unable to notify %s on %s/%s: %v", event.Reason, event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+	}
+}
+
+func flapEvent(cluster string, object v1.ObjectReference, eventType, reason, message string) *v1.Event {
+	now := unversioned.Now()
+	event := &v1.Event{
+		InvolvedObject: object,
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	event.ClusterName = cluster
+	return event
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlapCountsOnlyRecoveries(t *testing.T) {
+	withConfig(t, &Config{})
+	withNotifier(t, multiNotifier{&fakeSink{name: "slack"}})
+	d := newFlapDetector(2, time.Hour)
+
+	warning := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+	for i := 0; i < 5; i++ {
+		if d.held(warning) {
+			t.Fatal("a failing object was held back as flapping")
+		}
+		for _, reason := range []string{"Pulled", "Created", "Started", "Scheduled"} {
+			if d.held(normalEvent("app", reason)) {
+				t.Fatalf("routine %s event held back", reason)
+			}
+		}
+	}
+
+	recovery := recoveryEvent(warning)
+	held := false
+	for i := 0; i < 3 && !held; i++ {
+		held = d.held(recovery) || d.held(warning)
+	}
+	if !held {
+		t.Error("an object alternating between failing and recovered was not held back as flapping")
+	}
+}
+
+func TestFlapsArePerCluster(t *testing.T) {
+	withConfig(t, &Config{})
+	withNotifier(t, multiNotifier{&fakeSink{name: "slack"}})
+	d := newFlapDetector(1, time.Hour)
+
+	east := testEvent("app", "Pod", "web-1", "BackOff", "")
+	east.ClusterName = "east"
+	west := testEvent("app", "Pod", "web-1", "BackOff", "")
+	west.ClusterName = "west"
+
+	d.held(east)
+	d.held(recoveryEvent(west))
+	d.held(west)
+	if d.held(recoveryEvent(east)) {
+		t.Error("the same object in two clusters was taken for one flapping object")
+	}
+}
+
+func TestSimulateHoldsFlappingObject(t *testing.T) {
+	withConfig(t, &Config{})
+	withNotifier(t, multiNotifier{&fakeSink{name: "slack"}})
+	saved := flaps
+	flaps = newFlapDetector(1, time.Hour)
+	t.Cleanup(func() { flaps = saved })
+
+	warning := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+	flaps.held(warning)
+	if flaps.wouldHold(recoveryEvent(warning)) {
+		t.Fatal("the first recovery would be held back")
+	}
+	flaps.held(recoveryEvent(warning))
+	if got := decide(nil, warning, true).action; got != "flapping" {
+		t.Errorf("simulated decision %q, want flapping", got)
+	}
+	if !flaps.held(warning) {
+		t.Error("the simulation differs from the flap detector")
+	}
+}
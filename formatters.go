@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// Formatter renders the Slack message of an event as the JSON document
+// posted to Slack.
+type Formatter interface {
+	Format(event *v1.Event, extra *enrichment) ([]byte, error)
+}
+
+// FormatterFunc adapts a function to the Formatter interface.
+type FormatterFunc func(event *v1.Event, extra *enrichment) ([]byte, error)
+
+func (f FormatterFunc) Format(event *v1.Event, extra *enrichment) ([]byte, error) {
+	return f(event, extra)
+}
+
+// formatters are the formats SLACK_FORMAT can select, by name.
+var formatters = map[string]Formatter{
+	"legacy":  FormatterFunc(legacyFormat),
+	"blocks":  FormatterFunc(blocksFormat),
+	"compact": FormatterFunc(compactFormat),
+}
+
+// RegisterFormatter makes a format selectable with SLACK_FORMAT. Custom
+// formats are registered from the init function of the file adding them.
+func RegisterFormatter(name string, f Formatter) {
+	formatters[name] = f
+}
+
+// formatterNames lists the registered formats, the default one first.
+func formatterNames() []string {
+	names := []string{"legacy"}
+	for name := range formatters {
+		if name != "legacy" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// formatSlackMessage renders the event in SLACK_FORMAT.
+func formatSlackMessage(event *v1.Event, extra *enrichment) (SlackMessage, error) {
+	var message SlackMessage
+	data, err := formatters[cfg.SlackFormat].Format(event, extra)
+	if err != nil {
+		return message, fmt.Errorf("unable to format the message as %s: %v", cfg.SlackFormat, err)
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return message, fmt.Errorf("the %s format rendered an invalid message: %v", cfg.SlackFormat, err)
+	}
+	return message, nil
+}
+
+// legacyFormat is the attachment with fields messages have always been
+// posted as.
+func legacyFormat(event *v1.Event, extra *enrichment) ([]byte, error) {
+	return json.Marshal(buildSlackMessage("slack", event, extra))
+}
+
+// compactFormat is a single line of text without attachment.
+func compactFormat(event *v1.Event, extra *enrichment) ([]byte, error) {
+	legacy := buildSlackMessage("slack", event, extra)
+	text := fmt.Sprintf("*%s* %s/%s: %s", event.Reason, event.InvolvedObject.Namespace, event.InvolvedObject.Name, strings.Replace(messageText("slack", event, extra), "\n", " ", -1))
+	if legacy.Text != "" {
+		text = legacy.Text + " " + text
+	}
+	return json.Marshal(SlackMessage{Text: text})
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackBlockMaxFields is the most fields Slack accepts in a section block.
+const slackBlockMaxFields = 10
+
+// blocksFormat renders the attachment of the legacy format as Block Kit
+// sections: the title and message, the fields and the footer as context.
+func blocksFormat(event *v1.Event, extra *enrichment) ([]byte, error) {
+	legacy := buildSlackMessage("slack", event, extra)
+	attachment := legacy.Attachments[0]
+
+	title := "*" + slackLink(attachment.TitleLink, attachment.Title) + "* in " + slackLink(attachment.AuthorLink, attachment.AuthorName)
+	if legacy.Text != "" {
+		title = legacy.Text + " " + title
+	}
+	blocks := []slackBlock{{Type: "section", Text: &slackText{Type: "mrkdwn", Text: title + "\n" + attachment.Text}}}
+	var fields []slackText
+	for _, field := range attachment.Fields {
+		fields = append(fields, slackText{Type: "mrkdwn", Text: "*" + field.Title + "*\n" + field.Value})
+	}
+	for len(fields) > 0 {
+		n := len(fields)
+		if n > slackBlockMaxFields {
+			n = slackBlockMaxFields
+		}
+		blocks = append(blocks, slackBlock{Type: "section", Fields: fields[:n]})
+		fields = fields[n:]
+	}
+	if attachment.Footer != "" {
+		blocks = append(blocks, slackBlock{Type: "context", Elements: []slackText{{Type: "mrkdwn", Text: attachment.Footer}}})
+	}
+
+	encoded, err := json.Marshal(blocks)
+	if err != nil {
+		return nil, err
+	}
+	// The text is what notifications show, the blocks replacing it in the
+	// channel.
+	text := fmt.Sprintf("%s on %s/%s", event.Reason, event.InvolvedObject.Namespace, event.InvolvedObject.Name)
+	return json.Marshal(SlackMessage{Text: text, Blocks: encoded})
+}
+
+func slackLink(url, text string) string {
+	if url == "" {
+		return text
+	}
+	return "<" + url + "|" + text + ">"
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// The types below are the subset of Google Chat's cardsV2 message format
+// used to render an event.
+type googleChatMessage struct {
+	CardsV2 []googleChatCardWithID `json:"cardsV2"`
+}
+
+type googleChatCardWithID struct {
+	CardID string         `json:"cardId"`
+	Card   googleChatCard `json:"card"`
+}
+
+type googleChatCard struct {
+	Header   googleChatHeader    `json:"header"`
+	Sections []googleChatSection `json:"sections"`
+}
+
+type googleChatHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+type googleChatSection struct {
+	Widgets []googleChatWidget `json:"widgets"`
+}
+
+type googleChatWidget struct {
+	DecoratedText *googleChatDecoratedText `json:"decoratedText,omitempty"`
+	TextParagraph *googleChatText          `json:"textParagraph,omitempty"`
+	ButtonList    *googleChatButtonList    `json:"buttonList,omitempty"`
+}
+
+type googleChatDecoratedText struct {
+	TopLabel string `json:"topLabel"`
+	Text     string `json:"text"`
+}
+
+type googleChatText struct {
+	Text string `json:"text"`
+}
+
+type googleChatButtonList struct {
+	Buttons []googleChatButton `json:"buttons"`
+}
+
+type googleChatButton struct {
+	Text    string            `json:"text"`
+	OnClick googleChatOnClick `json:"onClick"`
+}
+
+type googleChatOnClick struct {
+	OpenLink struct {
+		URL string `json:"url"`
+	} `json:"openLink"`
+}
+
+// googleChatNotifier posts events as cards to GOOGLE_CHAT_WEBHOOK_URL.
+type googleChatNotifier struct {
+	url string
+}
+
+func (googleChatNotifier) Name() string { return "googlechat" }
+
+// buildGoogleChatMessage renders the event as a card. Chat cards have no
+// colored border like Slack attachments, so the severity is shown as
+// colored text instead.
+func buildGoogleChatMessage(event *v1.Event, extra *enrichment) googleChatMessage {
+	severity, color := event.Type, "#daa038"
+	if event.Type == "Normal" {
+		color = "#2eb886"
+	}
+	if extra.OOMKilled != nil {
+		severity, color = "OOMKilled", "#8b0000"
+	}
+
+	widgets := []googleChatWidget{
+		{DecoratedText: &googleChatDecoratedText{TopLabel: "Severity", Text: fmt.Sprintf(`<font color="%s">%s</font>`, color, html.EscapeString(severity))}},
+	}
+	for _, field := range slackFields(event, extra) {
+		widgets = append(widgets, googleChatWidget{DecoratedText: &googleChatDecoratedText{TopLabel: field.Title, Text: html.EscapeString(field.Value)}})
+	}
+	widgets = append(widgets, googleChatWidget{TextParagraph: &googleChatText{Text: html.EscapeString(messageText("googlechat", event, extra))}})
+	if link := resourceUrl(event); link != "" {
+		button := googleChatButton{Text: "Open in console"}
+		button.OnClick.OpenLink.URL = link
+		widgets = append(widgets, googleChatWidget{ButtonList: &googleChatButtonList{Buttons: []googleChatButton{button}}})
+	}
+	if extra.Skipped {
+		widgets = append(widgets, googleChatWidget{TextParagraph: &googleChatText{Text: enrichmentSkippedNote}})
+	}
+
+	return googleChatMessage{CardsV2: []googleChatCardWithID{{
+		CardID: "event",
+		Card: googleChatCard{
+			Header:   googleChatHeader{Title: event.InvolvedObject.Name, Subtitle: event.InvolvedObject.Namespace},
+			Sections: []googleChatSection{{Widgets: widgets}},
+		},
+	}}}
+}
+
+func (n googleChatNotifier) Notify(event *v1.Event, extra *enrichment) error {
+	body, err := json.Marshal(buildGoogleChatMessage(event, extra))
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(n.url, "application/json; charset=UTF-8", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("google chat responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// The grpc.health.v1.Health service is served by hand over unencrypted
+// HTTP/2 rather than through grpc-go, its two messages having a single
+// field each. Check answers for the whole server ("") as well as for the
+// "liveness" and "readiness" services, which all reflect /ready as /healthz
+// does. Watch is not implemented, probes only calling Check.
+
+// gRPC status codes.
+const (
+	grpcOK            = 0
+	grpcInvalidArg    = 3
+	grpcNotFound      = 5
+	grpcUnimplemented = 12
+)
+
+// grpc.health.v1.HealthCheckResponse.ServingStatus values.
+const (
+	healthServing    = 1
+	healthNotServing = 2
+)
+
+// serveGRPCHealth serves the health service on GRPC_HEALTH_ADDR.
+func serveGRPCHealth(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/grpc.health.v1.Health/Check", grpcHealthCheck)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		grpcError(w, grpcUnimplemented, "unknown method "+r.URL.Path)
+	})
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{Addr: addr, Handler: mux, Protocols: &protocols}
+	log.Printf("Serving gRPC health checks on %s", addr)
+	fatal(exitStartup, "Unable to serve gRPC health checks on %s: %v", addr, server.ListenAndServe())
+}
+
+func grpcHealthCheck(w http.ResponseWriter, r *http.Request) {
+	message, err := readGRPCMessage(r.Body)
+	if err != nil {
+		grpcError(w, grpcInvalidArg, err.Error())
+		return
+	}
+	service, err := healthCheckService(message)
+	if err != nil {
+		grpcError(w, grpcInvalidArg, err.Error())
+		return
+	}
+	switch service {
+	case "", "liveness", "readiness":
+	default:
+		grpcError(w, grpcNotFound, "unknown service "+service)
+		return
+	}
+	status := byte(healthServing)
+	if readiness() != nil {
+		status = healthNotServing
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status")
+	// A length-prefixed HealthCheckResponse: field 1, a varint, is the
+	// status.
+	w.Write([]byte{0, 0, 0, 0, 2, 0x08, status})
+	w.Header().Set("Grpc-Status", strconv.Itoa(grpcOK))
+}
+
+// grpcError answers with a status and no message, the status going in the
+// headers as gRPC allows for such trailers-only responses.
+func grpcError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", message)
+	w.WriteHeader(http.StatusOK)
+}
+
+// readGRPCMessage reads the single length-prefixed, uncompressed message of
+// a unary call.
+func readGRPCMessage(body io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 5 {
+		return nil, errors.New("truncated message")
+	}
+	if data[0] != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(data[1:5])
+	if uint32(len(data)-5) < length {
+		return nil, errors.New("truncated message")
+	}
+	return data[5 : 5+length], nil
+}
+
+// healthCheckService decodes the service, field 1, of a protobuf
+// HealthCheckRequest, skipping any other field.
+func healthCheckService(message []byte) (string, error) {
+	service := ""
+	for len(message) > 0 {
+		tag, n := binary.Uvarint(message)
+		if n <= 0 {
+			return "", errors.New("invalid request")
+		}
+		message = message[n:]
+		switch tag & 7 {
+		case 0:
+			_, n = binary.Uvarint(message)
+			if n <= 0 {
+				return "", errors.New("invalid request")
+			}
+			message = message[n:]
+		case 1, 5:
+			size := 8
+			if tag&7 == 5 {
+				size = 4
+			}
+			if len(message) < size {
+				return "", errors.New("invalid request")
+			}
+			message = message[size:]
+		case 2:
+			length, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < length {
+				return "", errors.New("invalid request")
+			}
+			value := message[n : n+int(length)]
+			if tag>>3 == 1 {
+				service = string(value)
+			}
+			message = message[n+int(length):]
+		default:
+			return "", errors.New("invalid request")
+		}
+	}
+	return service, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// historyBufferSize bounds the records waiting for the next export, so a
+// storm or an unwritable file can't grow the buffer without limit.
+const historyBufferSize = 10000
+
+// historyExport appends every notification decision to HISTORY_EXPORT_PATH
+// as JSON lines, for analyses spanning more than the logs keep. Records are
+// buffered and written every HISTORY_EXPORT_INTERVAL. The file is rotated
+// once it reaches HISTORY_EXPORT_MAX_SIZE or has been written to for
+// HISTORY_EXPORT_ROTATE, rotated files being suffixed with the time of the
+// rotation and only the last HISTORY_EXPORT_KEEP of them kept.
+type historyExport struct {
+	path    string
+	maxSize int64
+	rotate  time.Duration
+	keep    int
+
+	mu      sync.Mutex
+	pending []historyRecord
+
+	// Only used by the exporting goroutine.
+	file    *os.File
+	size    int64
+	started time.Time
+}
+
+type historyRecord struct {
+	Time      time.Time `json:"time"`
+	Decision  string    `json:"decision"`
+	Detail    string    `json:"detail"`
+	Key       string    `json:"key"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Namespace string    `json:"namespace"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason"`
+	Severity  string    `json:"severity"`
+	Count     int32     `json:"count"`
+}
+
+var (
+	history *historyExport
+
+	historyDropped = newCounterVec("history_records_dropped_total", "Notification history records dropped as the export buffer was full.")
+)
+
+func newHistoryExport(path string, maxSize int64, rotate time.Duration, keep int) (*historyExport, error) {
+	h := &historyExport{path: path, maxSize: maxSize, rotate: rotate, keep: keep}
+	if err := h.open(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *historyExport) open() error {
+	file, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	h.file, h.size, h.started = file, info.Size(), time.Now()
+	return nil
+}
+
+// add buffers a decision about the event for the next export.
+func (h *historyExport) add(event *v1.Event, decision, detail, key string) {
+	record := historyRecord{
+		Time:      time.Now().UTC(),
+		Decision:  decision,
+		Detail:    detail,
+		Key:       key,
+		Cluster:   event.ClusterName,
+		Namespace: event.InvolvedObject.Namespace,
+		Kind:      event.InvolvedObject.Kind,
+		Name:      event.InvolvedObject.Name,
+		Type:      event.Type,
+		Reason:    event.Reason,
+		Severity:  severityOf(event),
+		Count:     event.Count,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.pending) >= historyBufferSize {
+		historyDropped.inc()
+		return
+	}
+	h.pending = append(h.pending, record)
+}
+
+func (h *historyExport) run(interval time.Duration) {
+	for range time.Tick(interval) {
+		h.export()
+	}
+}
+
+// export writes the buffered records, rotating the file as needed.
+func (h *historyExport) export() {
+	if h.file == nil {
+		// Reopening failed after a rotation, try again.
+		if err := h.open(); err != nil {
+			log.Printf("Unable to open the notification history: %v", err)
+			return
+		}
+	}
+	h.mu.Lock()
+	records := h.pending
+	h.pending = nil
+	h.mu.Unlock()
+	if len(records) == 0 {
+		return
+	}
+
+	writer := bufio.NewWriter(h.file)
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+		line = append(line, '\n')
+		if h.size > 0 && (h.size+int64(len(line)) > h.maxSize || time.Since(h.started) > h.rotate) {
+			if err := writer.Flush(); err != nil {
+				log.Printf("Unable to write the notification history: %v", err)
+			}
+			if err := h.rotateFile(); err != nil {
+				log.Printf("Unable to rotate the notification history: %v", err)
+			}
+			if h.file == nil {
+				return
+			}
+			writer = bufio.NewWriter(h.file)
+		}
+		n, _ := writer.Write(line)
+		h.size += int64(n)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Printf("Unable to write the notification history: %v", err)
+	}
+}
+
+// rotatedName is the name the file is rotated to: suffixed with the current
+// time and a sequence number telling apart the files rotated within the
+// same second.
+func (h *historyExport) rotatedName() string {
+	stamp := time.Now().UTC().Format("20060102-150405")
+	for seq := 0; ; seq++ {
+		name := fmt.Sprintf("%s.%s-%03d", h.path, stamp, seq)
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			return name
+		}
+	}
+}
+
+// rotateFile renames the file with the current time, starts a new one and
+// deletes the rotated files beyond HISTORY_EXPORT_KEEP. When renaming fails
+// the file is kept on; h.file is nil only if it can't be reopened.
+func (h *historyExport) rotateFile() error {
+	h.file.Close()
+	h.file = nil
+	if err := os.Rename(h.path, h.rotatedName()); err != nil {
+		if openErr := h.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	rotated, err := filepath.Glob(h.path + ".*")
+	if err == nil && len(rotated) > h.keep {
+		// The time and sequence suffixes sort chronologically.
+		sort.Strings(rotated)
+		for _, name := range rotated[:len(rotated)-h.keep] {
+			if err := os.Remove(name); err != nil {
+				log.Printf("Unable to delete old notification history %s: %v", name, err)
+			}
+		}
+	}
+	return h.open()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryRotatesWithinOneSecond(t *testing.T) {
+	withConfig(t, &Config{})
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	// A one byte limit rotates the file before every record but the first.
+	h, err := newHistoryExport(path, 1, time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { h.file.Close() }()
+
+	for i := 0; i < 3; i++ {
+		h.add(testEvent("app", "Pod", "web-1", "BackOff", ""), "notified", "slack", "key")
+	}
+	h.export()
+	rotated, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Errorf("rotated to %v, want 2 files", rotated)
+	}
+}
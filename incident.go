@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// IncidentLinker looks up incidents already opened about an object, so
+// events about it are added to the incident being worked instead of being
+// notified again.
+type IncidentLinker interface {
+	// OpenIncident returns the ID of an open incident covering the event's
+	// object or namespace, or "" when there is none.
+	OpenIncident(event *v1.Event) (string, error)
+	// Comment adds the event to the incident.
+	Comment(id string, event *v1.Event, extra *enrichment) error
+}
+
+// noIncidents is the IncidentLinker used without an incident system.
+type noIncidents struct{}
+
+func (noIncidents) OpenIncident(event *v1.Event) (string, error) { return "", nil }
+
+func (noIncidents) Comment(id string, event *v1.Event, extra *enrichment) error { return nil }
+
+var incidents IncidentLinker = noIncidents{}
+
+// httpIncidents talks to INCIDENT_API_URL:
+//
+//	GET  /incidents?namespace=&kind=&name=  the open incident as {"id": "..."}, or 404
+//	POST /incidents/<id>/comments           the event as a generic webhook document
+type httpIncidents struct {
+	url string
+}
+
+func (l httpIncidents) OpenIncident(event *v1.Event) (string, error) {
+	query := url.Values{}
+	query.Set("namespace", event.InvolvedObject.Namespace)
+	query.Set("kind", event.InvolvedObject.Kind)
+	query.Set("name", event.InvolvedObject.Name)
+	resp, err := httpClient.Get(l.url + "/incidents?" + query.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("incident API responded %s", resp.Status)
+	}
+	var incident struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&incident); err != nil {
+		return "", err
+	}
+	return incident.ID, nil
+}
+
+func (l httpIncidents) Comment(id string, event *v1.Event, extra *enrichment) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(newWebhookPayload("webhook", event, extra)); err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(l.url+"/incidents/"+url.PathEscape(id)+"/comments", "application/json", body)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("incident API responded %s", resp.Status)
+	}
+	return nil
+}
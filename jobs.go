@@ -0,0 +1,63 @@
+package main
+
+import (
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// jobCompletionReasons are the Normal reasons of Jobs and CronJobs that ran
+// as intended.
+var jobCompletionReasons = []string{"Completed", "SawCompletedJob"}
+
+// jobCompleted reports whether the event is a Job or CronJob completing
+// successfully, which with JOB_AWARE is never notified, even when Normal
+// events are.
+func jobCompleted(event *v1.Event) bool {
+	if !cfg.JobAware || event.Type != "Normal" {
+		return false
+	}
+	if kind := event.InvolvedObject.Kind; kind != "Job" && kind != "CronJob" {
+		return false
+	}
+	for _, reason := range jobCompletionReasons {
+		if canonicalReason(reason) == canonicalReason(event.Reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// jobOwners resolves the Job of the event's object, a Job or one of its
+// pods, and the CronJob that scheduled it, if any. Both are empty for
+// objects unrelated to Jobs.
+func jobOwners(clientset *kubernetes.Clientset, event *v1.Event) (string, string, error) {
+	object := event.InvolvedObject
+	kind, name := object.Kind, object.Name
+	if kind == "CronJob" {
+		return "", name, nil
+	}
+	if kind == "Pod" {
+		meta, err := objectMeta(clientset, object.Namespace, kind, name)
+		if err != nil || meta == nil {
+			return "", "", err
+		}
+		owner := controllerOf(meta)
+		if owner == nil || owner.Kind != "Job" {
+			return "", "", nil
+		}
+		kind, name = owner.Kind, owner.Name
+	}
+	if kind != "Job" {
+		return "", "", nil
+	}
+	meta, err := objectMeta(clientset, object.Namespace, kind, name)
+	if err != nil {
+		return "", "", err
+	}
+	if meta != nil {
+		if owner := controllerOf(meta); owner != nil && owner.Kind == "CronJob" {
+			return name, owner.Name, nil
+		}
+	}
+	return name, "", nil
+}
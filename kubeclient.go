@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+	utilnet "k8s.io/client-go/pkg/util/net"
+	"k8s.io/client-go/rest"
+)
+
+// authFailureThreshold is the number of consecutive watch attempts rejected
+// for authentication before the clientset is rebuilt.
+const authFailureThreshold = 3
+
+var authReconnects = newCounterVec("kubernetes_auth_reconnects_total", "Clientsets rebuilt after the API server rejected the credentials.")
+
+var (
+	kubeClientMu sync.RWMutex
+	kubeClient   *kubernetes.Clientset
+)
+
+func currentClientset() *kubernetes.Clientset {
+	kubeClientMu.RLock()
+	defer kubeClientMu.RUnlock()
+	return kubeClient
+}
+
+// connect (re)builds the clientset from the mounted service account. The
+// in-cluster config reads the token and CA once, so a rotated token or
+// serving certificate is only picked up by building a new clientset; the
+// transport cache is keyed on the CA contents, so a new CA gets a new
+// transport rather than the stale one.
+func connect() error {
+	return inCluster.connect()
+}
+
+// isAuthError reports whether the API server rejected the credentials or
+// its certificate could not be verified.
+func isAuthError(err error) bool {
+	return apierrors.IsUnauthorized(err) || strings.Contains(err.Error(), "x509:")
+}
+
+// watchForever restarts the event watch of the cluster whenever it ends,
+// reconnecting with fresh credentials once authentication has failed
+// authFailureThreshold times in a row instead of crash-looping. With
+// EXIT_ON_WATCH_FAILURE the process exits instead, for Kubernetes to
+// restart the pod. A cluster that can't be reached is retried on its own
+// without affecting the others.
+func watchForever(c *cluster) {
+	failures, rapid := 0, 0
+	var lastHeal time.Time
+	for {
+		started := time.Now()
+		err := watchEvents(c.clientset())
+		if time.Since(started) < rapidWatchClose {
+			rapid++
+		} else {
+			rapid = 0
+		}
+		switch {
+		case err == nil:
+			failures = 0
+		case cfg.ExitOnWatchFailure:
+			fatal(exitWatch, "Unable to watch events of %s: %v", c.describe(), err)
+		case isAuthError(err):
+			failures++
+			log.Printf("Watch of %s rejected by the API server (%d/%d): %v", c.describe(), failures, authFailureThreshold, err)
+			if failures >= authFailureThreshold {
+				if err := c.connect(); err != nil {
+					log.Printf("Unable to rebuild the Kubernetes client of %s: %v", c.describe(), err)
+				} else {
+					log.Printf("Rebuilt the Kubernetes client of %s with fresh credentials", c.describe())
+					authReconnects.inc()
+					failures = 0
+				}
+			}
+		default:
+			failures = 0
+			log.Printf("Unable to watch events of %s: %v", c.describe(), err)
+		}
+		if cfg.WatchHealThreshold > 0 && rapid >= cfg.WatchHealThreshold && time.Since(lastHeal) >= cfg.WatchHealInterval {
+			selfHeal(c, rapid)
+			lastHeal, rapid = time.Now(), 0
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+var (
+	apiPingFailures     = newCounterVec("api_ping_failures_total", "Keepalive pings the API server failed to answer.")
+	watchPingReconnects = newCounterVec("watch_ping_reconnects_total", "Watches reconnected as the API server stopped answering keepalive pings.")
+)
+
+// keepalive pings the API server every WATCH_PING_INTERVAL until done is
+// closed. A watch whose connection is wedged looks the same as a quiet
+// cluster, so once WATCH_PING_FAILURES pings in a row went unanswered the
+// watch is stopped, to be reconnected by watchForever.
+func keepalive(clientset *kubernetes.Clientset, stop func(), done <-chan struct{}) {
+	ticker := time.NewTicker(cfg.WatchPingInterval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		err := pingAPI(clientset, cfg.WatchPingInterval)
+		if err == nil {
+			failures = 0
+			continue
+		}
+		failures++
+		apiPingFailures.inc()
+		log.Printf("The API server did not answer a keepalive ping (%d/%d): %v", failures, cfg.WatchPingFailures, err)
+		if failures >= cfg.WatchPingFailures {
+			log.Println("Reconnecting the watch, the API server stopped answering")
+			watchPingReconnects.inc()
+			stop()
+			return
+		}
+	}
+}
+
+// pingAPI gets the version of the API server, failing unless it answers
+// within timeout. The version is cheap to serve, unlike a list of events
+// which the API servers we support return in full, ignoring limit.
+func pingAPI(clientset *kubernetes.Clientset, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- clientset.CoreV1().RESTClient().Get().AbsPath("/version").Do().Error()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("no answer within %v", timeout)
+	}
+}
+
+// rapidWatchClose is how soon a watch has to end to count as failing
+// rather than as expiring, which the API server does after minutes.
+const rapidWatchClose = 10 * time.Second
+
+var watchSelfHeals = newCounterVec("watch_self_heals_total", "Kubernetes clients rebuilt after the watch kept closing immediately.")
+
+// selfHeal rebuilds the Kubernetes client from scratch, dropping the pooled
+// connections client-go keeps for it, and reports it. It is called at most
+// once per WATCH_HEAL_INTERVAL so a persistent failure does not thrash.
+func selfHeal(c *cluster, rapid int) {
+	log.Printf("The watch of %s closed immediately %d times in a row, rebuilding the Kubernetes client", c.describe(), rapid)
+	config, err := c.restConfig()
+	if err == nil {
+		closeIdleConnections(config)
+		err = c.connect()
+	}
+	if err != nil {
+		log.Printf("Unable to rebuild the Kubernetes client of %s: %v", c.describe(), err)
+		return
+	}
+	watchSelfHeals.inc()
+	now := unversioned.Now()
+	event := &v1.Event{
+		Type:           "Warning",
+		Reason:         "WatchSelfHealed",
+		Message:        fmt.Sprintf("The event watch closed immediately %d times in a row, the Kubernetes client was rebuilt", rapid),
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	event.ClusterName = c.name
+	if err := notifier.Notify(event, &enrichment{}); err != nil {
+		log.Printf("Unable to notify the watch self-heal: %v", err)
+	}
+}
+
+// closeIdleConnections closes the idle connections of the transport
+// client-go caches and shares between clients built from the same config,
+// which a rebuilt client would otherwise reuse.
+func closeIdleConnections(config *rest.Config) {
+	transport, err := rest.TransportFor(config)
+	if err != nil {
+		return
+	}
+	for {
+		wrapper, ok := transport.(utilnet.RoundTripperWrapper)
+		if !ok {
+			break
+		}
+		transport = wrapper.WrappedRoundTripper()
+	}
+	if t, ok := transport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
+}
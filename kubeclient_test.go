@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPingAPIGetsVersion(t *testing.T) {
+	pinged := false
+	withCluster(t, "ping", map[string]func(w http.ResponseWriter){
+		"version": func(w http.ResponseWriter) {
+			pinged = true
+			w.Write([]byte(`{"major": "1", "minor": "5"}`))
+		},
+	})
+	if err := pingAPI(clusterClientset("ping"), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !pinged {
+		t.Error("the ping did not get the version of the API server")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// logLevel is the active level of the default logger. Output of the
+// standard log package is routed through it at info level.
+var logLevel = new(slog.LevelVar)
+
+func setupLogging() error {
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+			return err
+		}
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+	return nil
+}
+
+// logLevelHandler changes the log level on POST /loglevel?level=debug and
+// reports the active level as JSON.
+func logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		if err := logLevel.UnmarshalText([]byte(r.URL.Query().Get("level"))); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Info("Changed log level", "level", logLevel.Level())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"level": logLevel.Level().String()})
+}
+
+// requireAdminToken guards an operational endpoint with the shared
+// ADMIN_TOKEN, sent as a bearer token. The endpoint is disabled when no
+// token is configured, as some of them change the notifier's state.
+func requireAdminToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := secretEnv("ADMIN_TOKEN")
+		if err != nil {
+			http.Error(w, "unable to read the admin token", http.StatusInternalServerError)
+			return
+		}
+		if token == "" {
+			http.Error(w, "set ADMIN_TOKEN to enable this endpoint", http.StatusForbidden)
+			return
+		}
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAdminToken(t *testing.T) {
+	handler := requireAdminToken(func(w http.ResponseWriter, r *http.Request) {})
+	for _, test := range []struct {
+		token, authorization string
+		want                 int
+	}{
+		{"", "", http.StatusForbidden},
+		{"", "Bearer anything", http.StatusForbidden},
+		{"s3cret", "", http.StatusUnauthorized},
+		{"s3cret", "Bearer wrong", http.StatusUnauthorized},
+		{"s3cret", "Bearer s3cret", http.StatusOK},
+	} {
+		t.Setenv("ADMIN_TOKEN", test.token)
+		r := httptest.NewRequest("POST", "/deadletters/replay", nil)
+		if test.authorization != "" {
+			r.Header.Set("Authorization", test.authorization)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != test.want {
+			t.Errorf("token %q, authorization %q: status %d, want %d", test.token, test.authorization, w.Code, test.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache is a size-bounded, concurrency-safe cache whose entries also
+// expire after a TTL. It backs the API lookups made to enrich events so
+// storms do not translate into as many requests to the API server.
+type lruCache struct {
+	name    string
+	ttl     time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+var (
+	cacheHits      = newCounterVec("enrichment_cache_hits_total", "Lookups answered from an enrichment cache.", "cache")
+	cacheMisses    = newCounterVec("enrichment_cache_misses_total", "Lookups that had to query the API server.", "cache")
+	cacheEvictions = newCounterVec("enrichment_cache_evictions_total", "Entries evicted to keep an enrichment cache within its size.", "cache")
+)
+
+func newLRUCache(name string, ttl time.Duration, maxSize int) *lruCache {
+	return &lruCache{
+		name:    name,
+		ttl:     ttl,
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (c *lruCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *lruCache) set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		element.Value = &lruEntry{key: key, value: value, expires: expires}
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+		cacheEvictions.inc(c.name)
+	}
+}
+
+// fetch returns the cached value for key, calling load on a miss and caching
+// its result unless it failed.
+func (c *lruCache) fetch(key string, load func() (interface{}, error)) (interface{}, error) {
+	if value, ok := c.get(key); ok {
+		cacheHits.inc(c.name)
+		return value, nil
+	}
+	cacheMisses.inc(c.name)
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, value)
+	return value, nil
+}
@@ -3,14 +3,22 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/pkg/api/v1"
-	"k8s.io/client-go/rest"
+	"k8s.io/client-go/pkg/util/flowcontrol"
 	"strings"
 	"time"
 )
@@ -24,99 +32,609 @@ type SlackField struct {
 type SlackAttachment struct {
 	Color      string       `json:"color"`
 	AuthorName string       `json:"author_name"`
-	AuthorLink string       `json:"author_link"`
+	AuthorLink string       `json:"author_link,omitempty"`
 	Title      string       `json:"title"`
-	TitleLink  string       `json:"title_link"`
+	TitleLink  string       `json:"title_link,omitempty"`
 	Text       string       `json:"text"`
 	Fields     []SlackField `json:"fields"`
+	Footer     string       `json:"footer,omitempty"`
+	ThumbURL   string       `json:"thumb_url,omitempty"`
 }
 
 type SlackMessage struct {
-	Attachments []SlackAttachment `json:"attachments"`
+	Channel        string            `json:"channel,omitempty"`
+	Text           string            `json:"text,omitempty"`
+	ThreadTS       string            `json:"thread_ts,omitempty"`
+	ReplyBroadcast bool              `json:"reply_broadcast,omitempty"`
+	TS             string            `json:"ts,omitempty"`
+	Attachments    []SlackAttachment `json:"attachments,omitempty"`
+	Blocks         json.RawMessage   `json:"blocks,omitempty"`
 }
 
+var notificationLatency = newHistogramVec(
+	"slack_notification_latency_seconds",
+	"Time between the event's last occurrence and its notification being sent.",
+	[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	"namespace",
+)
+
+func observeLatency(event *v1.Event) {
+	occurred := event.LastTimestamp.Time
+	if occurred.IsZero() {
+		occurred = event.FirstTimestamp.Time
+	}
+	if occurred.IsZero() {
+		return
+	}
+	notificationLatency.observe(time.Since(occurred).Seconds(), event.InvolvedObject.Namespace)
+}
+
+// consoleUrl returns OPENSHIFT_CONSOLE_URL without a trailing slash, or an
+// empty string when unset, in which case no links are rendered.
+func consoleUrl() string {
+	return strings.TrimRight(os.Getenv("OPENSHIFT_CONSOLE_URL"), "/")
+}
+
+// resourceUrl links to the event's object in the console, or is empty when
+// the event does not name the object's kind.
 func resourceUrl(event *v1.Event) string {
-	return os.Getenv("OPENSHIFT_CONSOLE_URL") + "/project/" + event.InvolvedObject.Namespace + "/browse/" + strings.ToLower(event.InvolvedObject.Kind) + "s/" + event.InvolvedObject.Name
+	if consoleUrl() == "" || event.InvolvedObject.Kind == "" {
+		return ""
+	}
+	return consoleUrl() + "/project/" + event.InvolvedObject.Namespace + "/browse/" + strings.ToLower(event.InvolvedObject.Kind) + "s/" + event.InvolvedObject.Name
 }
 
 func monitoringUrl(event *v1.Event) string {
-	return os.Getenv("OPENSHIFT_CONSOLE_URL") + "project/" + event.InvolvedObject.Namespace + "/monitoring"
+	if consoleUrl() == "" {
+		return ""
+	}
+	return consoleUrl() + "/project/" + event.InvolvedObject.Namespace + "/monitoring"
+}
+
+// authorLink is where the namespace shown as the author of a message links
+// to, according to AUTHOR_LINK_MODE.
+func authorLink(event *v1.Event) string {
+	namespace := event.InvolvedObject.Namespace
+	switch cfg.AuthorLinkMode {
+	case "overview":
+		if consoleUrl() == "" {
+			return ""
+		}
+		return consoleUrl() + "/project/" + namespace + "/overview"
+	case "grafana":
+		separator := "?"
+		if strings.Contains(cfg.AuthorLinkTemplate, "?") {
+			separator = "&"
+		}
+		return cfg.AuthorLinkTemplate + separator + "var-namespace=" + url.QueryEscape(namespace)
+	case "custom-template":
+		return strings.Replace(cfg.AuthorLinkTemplate, "{namespace}", url.PathEscape(namespace), -1)
+	case "none":
+		return ""
+	}
+	return monitoringUrl(event)
+}
+
+// messageText is the message rendered for the backend, preceded by the
+// MESSAGE_PREFIX banner when one is configured.
+func messageText(backend string, event *v1.Event, extra *enrichment) string {
+	text := renderMessage(backend, event, extra)
+	if cfg.MessagePrefix == "" {
+		return text
+	}
+	return cfg.MessagePrefix + " " + text
 }
 
-func notifySlack(event *v1.Event) {
-	webhookUrl := os.Getenv("SLACK_WEBHOOK_URL")
+// oomColor marks the messages of OOM killed containers.
+const oomColor = "#8b0000"
+
+const enrichmentSkippedNote = "Enrichment skipped, the API server was too slow to respond or ENRICHMENT_QPS was exceeded"
+
+func buildSlackMessage(backend string, event *v1.Event, extra *enrichment) SlackMessage {
+	color := "warning"
+	if event.Type == "Normal" {
+		color = "good"
+	}
 	message := SlackMessage{
 		Attachments: []SlackAttachment{
 			{
-				Color:      "warning",
+				Color:      color,
 				AuthorName: event.InvolvedObject.Namespace,
-				AuthorLink: monitoringUrl(event),
+				AuthorLink: authorLink(event),
 				Title:      event.InvolvedObject.Name,
 				TitleLink:  resourceUrl(event),
-				Text:       event.Message,
-				Fields: []SlackField{
-					{
-						Title: "Reason",
-						Value: event.Reason,
-						Short: true,
-					},
-					{
-						Title: "Kind",
-						Value: event.InvolvedObject.Kind,
-						Short: true,
-					},
-				},
+				Text:       messageText(backend, event, extra),
+				Fields:     slackFields(event, extra),
+				ThumbURL:   cfg.ThumbURLs[severityOf(event)],
 			},
 		},
 	}
-	messageJson, err := json.Marshal(message)
+	switch p, destination := priorityOf(event), route(event); {
+	case p == priorityLow:
+		// Posted quietly, whatever the rule's mention.
+	case destination.Mention != "":
+		message.Text = destination.Mention
+	case p == priorityHigh:
+		message.Text = cfg.SlackMention
+	}
+	if extra.OOMKilled != nil {
+		message.Attachments[0].Color = oomColor
+		message.Attachments[0].Title = "OOMKilled: " + message.Attachments[0].Title
+	}
+	message.Attachments[0].Color = escalateColor(message.Attachments[0].Color, event)
+	if extra.Skipped {
+		message.Attachments[0].Footer = enrichmentSkippedNote
+	}
+	return message
+}
+
+// notifySlack posts the event where route sends it: to the named
+// SLACK_DESTINATIONS or the channel of the NOTIFY_RULES entry it matches,
+// or else to SLACK_CHANNEL or the default webhook pool. An event with
+// nowhere to go is an error rather than dropped silently.
+func notifySlack(event *v1.Event, extra *enrichment) error {
+	message, err := formatSlackMessage(event, extra)
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	var targets []slackDestination
+	destination := route(event)
+	switch {
+	case len(destination.Destinations) > 0:
+		if r := currentRouting(); r != nil {
+			for _, name := range destination.Destinations {
+				if target, ok := r.destinations[name]; ok {
+					targets = append(targets, target)
+				}
+			}
+		}
+	case currentBotToken() != "":
+		if destination.Channel != "" || cfg.SlackChannel != "" {
+			targets = []slackDestination{{Channel: destination.Channel}}
+		}
+	default:
+		if pool := currentWebhooks(); pool != nil {
+			targets = []slackDestination{{Webhook: pool.pick(), Channel: destination.Channel}}
+		}
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no Slack destination for %s on %s/%s", event.Reason, event.InvolvedObject.Namespace, event.InvolvedObject.Name)
+	}
+
+	var failed error
+	for _, target := range targets {
+		if err := sendSlack(target, message, event); err != nil {
+			failed = err
+		}
+	}
+	if failed != nil {
+		return failed
+	}
+	observeLatency(event)
+	return nil
+}
+
+// sendSlack posts the message to the destination, through the Web API
+// with its own token or, for destinations without a webhook, with
+// SLACK_BOT_TOKEN, and to its webhook otherwise.
+func sendSlack(target slackDestination, message SlackMessage, event *v1.Event) error {
+	token := target.Token
+	if token == "" && target.Webhook == "" {
+		token = currentBotToken()
+	}
+	if token == "" {
+		message.Channel = target.Channel
+		return postSlack(target.Webhook, message)
+	}
+	channel := target.Channel
+	if channel == "" {
+		channel = cfg.SlackChannel
+	}
+	var posted *slackAPIResponse
+	var err error
+	if threads != nil {
+		posted, err = threads.post(target.Token, channel, message, event)
+	} else {
+		posted, err = postSlackAPI(token, channel, message)
+	}
+	if err != nil {
+		return err
+	}
+	if series != nil {
+		series.posted(event, target.Token, channel, posted)
+	}
+	if cfg.LogPermalinks {
+		logPermalink(token, posted, event)
+	}
+	return nil
+}
+
+// postDefault posts a message that is not about a single event to the
+// default webhook pool, or to SLACK_CHANNEL in bot token mode.
+func postDefault(message SlackMessage) error {
+	if token := currentBotToken(); token != "" {
+		_, err := postSlackAPI(token, cfg.SlackChannel, message)
+		return err
+	}
+	pool := currentWebhooks()
+	if pool == nil {
+		return fmt.Errorf("no default Slack webhook configured")
+	}
+	return postSlack(pool.pick(), message)
+}
+
+// httpClient is shared so connections to the webhooks are kept alive
+// between messages.
+var httpClient = &http.Client{}
+
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+func postSlack(webhookUrl string, message SlackMessage) error {
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	defer bufferPool.Put(buffer)
+
+	if err := json.NewEncoder(buffer).Encode(message); err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", webhookUrl, buffer)
+	if err != nil {
+		return err
 	}
-	client := http.Client{}
-	req, err := http.NewRequest("POST", webhookUrl, bytes.NewBufferString(string(messageJson)))
 	req.Header.Set("Content-Type", "application/json")
-	_, err = client.Do(req)
+	setHeaders(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		fmt.Println("Unable to reach the server.")
+		log.Printf("Unable to reach the Slack webhook: %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		// Slack explains the failure in the body, e.g. "invalid_token".
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("slack responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
 	}
+	// Drain the body so the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
 }
 
-func watchEvents(clientset *kubernetes.Clientset) {
+var (
+	dedup      dedupStore
+	digests    *digest
+	batches    *batcher
+	recoveries *recoveryTracker
+	storms     *stormTracker
+)
+
+func handleEvent(clientset *kubernetes.Clientset, event *v1.Event) {
+	d := decide(clientset, event, false)
+	key, targets := d.key, d.targets
+	switch d.action {
+	case "notify":
+	case "digest":
+		digests.add(event)
+		targets.recordDelivered(key)
+		return
+	case "batch":
+		batches.add(clientset, event)
+		targets.recordDelivered(key)
+		return
+	case "incident":
+		slog.Debug("Added event to an open incident", "key", key)
+		targets.recordDelivered(key)
+		suppress(event, d.action)
+		return
+	case "startup-grace":
+		log.Printf("Skipping %s on %s %s/%s still within its startup grace", event.Reason, event.InvolvedObject.Kind, event.InvolvedObject.Namespace, event.InvolvedObject.Name)
+		suppress(event, d.action)
+		return
+	case "flapping":
+		if recoveries != nil {
+			// Its recovery is still a change of state to count.
+			recoveries.track(event)
+		}
+		fallthrough
+	default:
+		slog.Debug("Suppressed event", "key", key, "decision", d.action, "reason", event.Reason)
+		suppress(event, d.action)
+		return
+	}
+	extra := enrichWithin(clientset, event)
+	if cfg.ShowCountDelta {
+		extra.PreviousCount = dedup.lastCount(key)
+	}
+	if err := targets.deliver(key, event, extra); err != nil {
+		log.Printf("Unable to notify %s on %s/%s: %v", event.Reason, event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+		return
+	}
+	if cfg.ShowCountDelta {
+		dedup.recordCount(key, event.Count)
+	}
+	if series != nil {
+		series.track(event)
+	}
+	if recoveries != nil {
+		recoveries.track(event)
+	}
+	if cfg.NormalAfterWarning {
+		// A Normal event resolves the warning, later ones are routine
+		// again.
+		if event.Type == "Warning" {
+			dedup.record(warningKey(event))
+		} else {
+			dedup.release(warningKey(event))
+		}
+	}
+}
+
+// warningKey is the dedup cache key recording that the event's object had
+// a warning notified, for NORMAL_AFTER_WARNING.
+func warningKey(event *v1.Event) string {
+	return "warning\x00" + event.ClusterName + "/" + event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name
+}
+
+// watchEvents streams events until the watch ends, returning the error if
+// it could not be started. With WATCH_RESUME, a watch resumes after the
+// last event of the previous one unless the first event replayed is older
+// than REPLAY_MAX_GAP, in which case the backlog is skipped as on a fresh
+// start.
+func watchEvents(clientset *kubernetes.Clientset) error {
 	startTime := time.Now()
-	log.Printf("Watching events after %v", startTime)
+	resourceVersion := ""
+	resume, replaying := takeResumePoint(clientset)
+	if replaying {
+		startTime, resourceVersion = resume.since, resume.resourceVersion
+		log.Printf("Resuming events after resourceVersion %s", resourceVersion)
+	} else {
+		log.Printf("Watching events after %v", startTime)
+	}
 
-	watcher, err := clientset.CoreV1().Events("").Watch(v1.ListOptions{FieldSelector: "type=Warning"})
+	fieldSelector := ""
+	if watchesOnlyWarnings() {
+		fieldSelector = "type=Warning"
+	}
+	stream := streamCoreEvents
+	if useEventsV1(clientset) {
+		log.Println("Using the events.k8s.io/v1 API")
+		stream = streamEventsV1
+	}
+	events, stop, err := stream(clientset, fieldSelector, resourceVersion)
 	if err != nil {
-		panic(err.Error())
+		return err
+	}
+	if cfg.WatchPingInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go keepalive(clientset, stop, done)
+	}
+
+	for event := range events {
+		if replaying {
+			replaying = false
+			if gap := time.Since(lastSeenAt(event)); gap > cfg.ReplayMaxGap {
+				startTime = time.Now()
+				log.Printf("The replay after resourceVersion %s starts %v ago, beyond REPLAY_MAX_GAP: skipping the backlog and watching events after %v", resourceVersion, gap.Round(time.Second), startTime)
+			} else {
+				log.Printf("Replaying the events missed since resourceVersion %s", resourceVersion)
+			}
+		}
+		remember(clientset, event, startTime)
+		if cfg.NormalizeReasons {
+			event.Reason = strings.TrimSpace(event.Reason)
+		}
+		if selfEvent(event) {
+			slog.Debug("Ignored event recorded by the notifier", "reason", event.Reason, "namespace", event.InvolvedObject.Namespace, "name", event.InvolvedObject.Name)
+			continue
+		}
+		if !shouldNotifyTypeReason(event.Type, event.Reason) {
+			slog.Debug("Filtered event", "type", event.Type, "reason", event.Reason, "namespace", event.InvolvedObject.Namespace, "name", event.InvolvedObject.Name)
+			continue
+		}
+		if occurredAt(event).After(startTime) {
+			event.ClusterName = clusterName(clientset)
+			handleEvent(clientset, event)
+		}
+	}
+	return nil
+}
+
+// occurredAt is when the event first occurred. Some aggregated events have
+// no FirstTimestamp, in which case their LastTimestamp is the best there is.
+// Events of the events.k8s.io API already fall back to their EventTime when
+// converted.
+func occurredAt(event *v1.Event) time.Time {
+	if !event.FirstTimestamp.IsZero() {
+		return event.FirstTimestamp.Time
 	}
+	return event.LastTimestamp.Time
+}
 
-	for watchEvent := range watcher.ResultChan() {
-		event := watchEvent.Object.(*v1.Event)
-		if event.FirstTimestamp.Time.After(startTime) {
-			notifySlack(event)
+// reloadOnHangup re-reads the webhook configuration on SIGHUP so a rotated
+// secret file is picked up without restarting the pod.
+func reloadOnHangup() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	for range hangup {
+		if err := loadWebhooks(); err != nil {
+			log.Printf("Keeping previous webhooks, reload failed: %v", err)
+			continue
+		}
+		if err := loadRouting(); err != nil {
+			log.Printf("Keeping previous destinations, reload failed: %v", err)
+			continue
+		}
+		if err := reloadBotToken(); err != nil {
+			log.Printf("Keeping previous bot token, reload failed: %v", err)
+			continue
 		}
+		log.Println("Reloaded webhooks")
 	}
 }
 
+// configure loads and validates every setting, the same way at startup
+// and for --check-config.
+func configure() error {
+	if err := applyConfigFiles(); err != nil {
+		return err
+	}
+	if err := setupLogging(); err != nil {
+		return err
+	}
+	var err error
+	if cfg, err = loadConfig(); err != nil {
+		return err
+	}
+	botTokenMu.Lock()
+	botToken = cfg.SlackBotToken
+	botTokenMu.Unlock()
+	if err := loadWebhooks(); err != nil {
+		return err
+	}
+	if err := loadRouting(); err != nil {
+		return err
+	}
+	if err := loadTemplates(); err != nil {
+		return err
+	}
+	if notifier, err = newNotifier(); err != nil {
+		return err
+	}
+	return nil
+}
+
 func main() {
-	config, err := rest.InClusterConfig()
+	checkConfig := flag.Bool("check-config", false, "validate the configuration and exit")
+	flag.Parse()
+
+	err := configure()
+	if *checkConfig {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+			os.Exit(exitConfig)
+		}
+		fmt.Println("Configuration is valid")
+		return
+	}
 	if err != nil {
-		panic(err.Error())
+		fatal(exitConfig, "Invalid configuration: %v", err)
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		panic(err.Error())
+	objectCache = newLRUCache("objects", cfg.EnrichmentCacheTTL, cfg.EnrichmentCacheSize)
+	if cfg.EnrichmentQPS > 0 {
+		burst := int(cfg.EnrichmentQPS)
+		if burst < 1 {
+			burst = 1
+		}
+		enrichmentLimiter = flowcontrol.NewTokenBucketRateLimiter(float32(cfg.EnrichmentQPS), burst)
+	}
+	if cfg.DedupTTL > 0 {
+		dedup = newDedupCache(cfg.DedupTTL, cfg.DedupOngoingInterval, cfg.DedupBackoff)
+	}
+	if cfg.DigestInterval > 0 {
+		digests = newDigest(cfg.DigestGroupBy)
+		go digests.run(cfg.DigestInterval)
+	}
+	if cfg.BatchWindow > 0 {
+		batches, _ = newBatcher(cfg.BatchKey, cfg.BatchWindow)
+	}
+	if cfg.StormDetailLimit > 0 {
+		storms = newStormTracker(cfg.StormDetailLimit, cfg.StormSummaryInterval, cfg.StormQuietPeriod)
+		go storms.run()
+	}
+	if cfg.ThrottleKey != "" {
+		throttles, _ = newThrottle(cfg.ThrottleKey, cfg.ThrottleRate, cfg.ThrottleBurst)
+		go throttles.run()
+	}
+	if cfg.OutboxDir != "" {
+		if outboxes, err = newOutbox(cfg.OutboxDir, cfg.OutboxRetryInterval, cfg.OutboxMaxAge, cfg.DeadLetterMaxAge); err != nil {
+			fatal(exitStartup, "Unable to open the outbox: %v", err)
+		}
+		go outboxes.run()
+	}
+	if cfg.AuditLogPath != "" {
+		if audit, err = newAuditLog(cfg.AuditLogPath, int64(cfg.AuditLogMaxSize)<<20); err != nil {
+			fatal(exitStartup, "Unable to open the audit log: %v", err)
+		}
+	}
+	if cfg.HistoryExportPath != "" {
+		if history, err = newHistoryExport(cfg.HistoryExportPath, int64(cfg.HistoryExportMaxSize)<<20, cfg.HistoryExportRotate, cfg.HistoryExportKeep); err != nil {
+			fatal(exitStartup, "Unable to open the notification history: %v", err)
+		}
+		go history.run(cfg.HistoryExportInterval)
+	}
+	if cfg.IncidentAPIURL != "" {
+		incidents = httpIncidents{url: strings.TrimRight(cfg.IncidentAPIURL, "/")}
+	}
+	if cfg.FlapThreshold > 0 {
+		flaps = newFlapDetector(cfg.FlapThreshold, cfg.FlapWindow)
+		go flaps.run()
+	}
+	if cfg.FailureAlertAfter > 0 {
+		sends = newSendHealth(cfg.FailureAlertAfter, cfg.FailureMarkerFile)
+		go sends.run()
+	}
+	if cfg.SuppressionAlertRate > 0 {
+		suppressions = newSuppressionMonitor(cfg.SuppressionAlertRate, cfg.SuppressionAlertAfter)
+		go suppressions.run()
 	}
+	if cfg.SeriesMode != "off" {
+		series = newSeriesTracker(cfg.SeriesMode)
+	}
+	if cfg.SlackThreadByObject {
+		threads = newThreadTracker(cfg.SlackThreadTTL)
+	}
+	go reloadOnHangup()
 
-	go func() {
-		for {
-			watchEvents(clientset)
-			time.Sleep(5 * time.Second)
+	clusters := []*cluster{inCluster}
+	if len(cfg.ClusterContexts) > 0 {
+		clusters, err = connectClusters(cfg.ClusterContexts)
+	} else {
+		err = connect()
+	}
+	if err != nil {
+		fatal(exitStartup, "Unable to configure the Kubernetes client: %v", err)
+	}
+	if dedup != nil && cfg.DedupStore == "configmap" {
+		store, err := newConfigMapDedup(cfg.DedupConfigMap, cfg.DedupTTL, cfg.DedupOngoingInterval, cfg.DedupBackoff)
+		if err != nil {
+			fatal(exitStartup, "Unable to load the dedup ConfigMap: %v", err)
 		}
-	}()
+		dedup = store
+	}
+	if cfg.EmitEvents != "off" {
+		startRecorder()
+	}
+
+	if cfg.RecoveryCheckInterval > 0 {
+		recoveries = newRecoveryTracker()
+		go recoveries.run(cfg.RecoveryCheckInterval)
+	}
+
+	if cfg.WatchNodes {
+		go newNodeWatcher(cfg.NodeConditions).run()
+	}
+
+	if cfg.StartupSelfTest {
+		go runSelfTest()
+	}
+	for _, c := range clusters {
+		go watchForever(c)
+	}
+
+	if cfg.GRPCHealthAddr != "" {
+		go serveGRPCHealth(cfg.GRPCHealthAddr)
+	}
+
+	http.HandleFunc("/metrics", metricsHandler)
+	http.HandleFunc("/ready", readyHandler)
+	http.HandleFunc("/healthz", readyHandler)
+	http.HandleFunc("/loglevel", requireAdminToken(logLevelHandler))
+	http.HandleFunc("/simulate", requireAdminToken(simulateHandler))
+	http.HandleFunc("/config", requireAdminToken(configHandler))
+	http.HandleFunc("/deadletters/replay", requireAdminToken(replayHandler))
 
 	log.Println("Listening on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	fatal(exitStartup, "Unable to serve on port 8080: %v", http.ListenAndServe(":8080", nil))
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestPostSlackStatus(t *testing.T) {
+	withConfig(t, &Config{})
+	for _, test := range []struct {
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{http.StatusOK, "ok", false},
+		{http.StatusForbidden, "invalid_token", true},
+		{http.StatusNotFound, "no_service", true},
+		{http.StatusInternalServerError, "", true},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		}))
+		err := postSlack(server.URL, SlackMessage{Text: "test"})
+		server.Close()
+		if (err != nil) != test.wantErr {
+			t.Errorf("status %d: error %v, want error %v", test.status, err, test.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), test.body) {
+			t.Errorf("status %d: error %q does not include the body %q", test.status, err, test.body)
+		}
+	}
+}
+
+// renderingNotifier renders and encodes Slack messages without sending
+// them, leaving the network out of benchmarks.
+type renderingNotifier struct{}
+
+func (renderingNotifier) Name() string { return "slack" }
+
+func (renderingNotifier) Notify(event *v1.Event, extra *enrichment) error {
+	message, err := formatSlackMessage(event, extra)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(ioutil.Discard).Encode(message)
+}
+
+// BenchmarkHandleEvent feeds synthetic events through the per-event path,
+// filtering, dedup, rendering and encoding, with sends stubbed.
+func BenchmarkHandleEvent(b *testing.B) {
+	withConfig(b, &Config{SlackFormat: "legacy", NormalizeReasons: true, DedupIgnoreNumbers: true, EmptyMessage: "(no message)"})
+	withNotifier(b, multiNotifier{renderingNotifier{}})
+	events := make([]*v1.Event, 1024)
+	for i := range events {
+		events[i] = testEvent("app", "Pod", fmt.Sprintf("web-%d", i), "BackOff", fmt.Sprintf("Back-off restarting failed container, %d times", i))
+	}
+
+	for _, cache := range []struct {
+		name  string
+		store dedupStore
+	}{
+		{"unique", nil},
+		{"duplicates", newDedupCache(time.Hour, 0, nil)},
+	} {
+		b.Run(cache.name, func(b *testing.B) {
+			saved := dedup
+			dedup = cache.store
+			defer func() { dedup = saved }()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				event := events[i%len(events)]
+				if shouldNotifyTypeReason(event.Type, event.Reason) {
+					handleEvent(nil, event)
+				}
+			}
+		})
+	}
+}
+
+func TestNoLinksWithoutConsoleURL(t *testing.T) {
+	t.Setenv("OPENSHIFT_CONSOLE_URL", "")
+	event := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+	for _, mode := range []string{"monitoring", "overview"} {
+		withConfig(t, &Config{AuthorLinkMode: mode})
+		attachment := buildSlackMessage("slack", event, &enrichment{}).Attachments[0]
+		if attachment.AuthorLink != "" || attachment.TitleLink != "" {
+			t.Errorf("%s mode: links %q and %q without a console URL", mode, attachment.AuthorLink, attachment.TitleLink)
+		}
+	}
+}
+
+func TestConsoleLinks(t *testing.T) {
+	t.Setenv("OPENSHIFT_CONSOLE_URL", "https://console.example.com:8443/console/")
+	withConfig(t, &Config{AuthorLinkMode: "monitoring"})
+	attachment := buildSlackMessage("slack", testEvent("app", "Pod", "web-1", "BackOff", ""), &enrichment{}).Attachments[0]
+	if want := "https://console.example.com:8443/console/project/app/monitoring"; attachment.AuthorLink != want {
+		t.Errorf("author link %q, want %q", attachment.AuthorLink, want)
+	}
+	if want := "https://console.example.com:8443/console/project/app/browse/pods/web-1"; attachment.TitleLink != want {
+		t.Errorf("title link %q, want %q", attachment.TitleLink, want)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/watch"
+)
+
+// nodeWatcher notifies when a node condition listed in NODE_CONDITIONS turns
+// bad and when it recovers, catching node problems directly rather than
+// through the pod events they cause.
+type nodeWatcher struct {
+	conditions map[string]bool
+
+	// bad holds the conditions currently bad on each known node.
+	bad map[string]map[string]bool
+}
+
+func newNodeWatcher(conditions []string) *nodeWatcher {
+	w := &nodeWatcher{conditions: map[string]bool{}, bad: map[string]map[string]bool{}}
+	for _, condition := range conditions {
+		w.conditions[condition] = true
+	}
+	return w
+}
+
+func (w *nodeWatcher) run() {
+	for {
+		if err := w.watch(); err != nil {
+			log.Printf("Unable to watch nodes: %v", err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (w *nodeWatcher) watch() error {
+	watcher, err := currentClientset().CoreV1().Nodes().Watch(v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+	for watchEvent := range watcher.ResultChan() {
+		node, ok := watchEvent.Object.(*v1.Node)
+		if !ok {
+			return fmt.Errorf("node watch failed: %s", fmt.Sprint(watchEvent.Object))
+		}
+		if watchEvent.Type == watch.Deleted {
+			delete(w.bad, node.Name)
+			continue
+		}
+		w.update(node)
+	}
+	return nil
+}
+
+// update compares the node's conditions with the last seen ones. Nodes seen
+// for the first time are only recorded, as their state predates the watch.
+func (w *nodeWatcher) update(node *v1.Node) {
+	previous, known := w.bad[node.Name]
+	current := map[string]bool{}
+	for _, condition := range node.Status.Conditions {
+		name := string(condition.Type)
+		if !w.conditions[name] || !conditionBad(condition) {
+			continue
+		}
+		current[name] = true
+		if known && !previous[name] {
+			w.notify(node, condition, "Warning")
+		}
+	}
+	if known {
+		for _, condition := range node.Status.Conditions {
+			name := string(condition.Type)
+			if previous[name] && !current[name] {
+				w.notify(node, condition, "Normal")
+			}
+		}
+	}
+	w.bad[node.Name] = current
+}
+
+// conditionBad reports whether the condition is in its bad state: Ready is
+// bad unless true, the pressure and availability conditions when true.
+func conditionBad(condition v1.NodeCondition) bool {
+	if condition.Type == v1.NodeReady {
+		return condition.Status != v1.ConditionTrue
+	}
+	return condition.Status == v1.ConditionTrue
+}
+
+func (w *nodeWatcher) notify(node *v1.Node, condition v1.NodeCondition, eventType string) {
+	event := nodeEvent(node, condition, eventType)
+	if flaps != nil && flaps.held(event) {
+		return
+	}
+	if err := notifier.Notify(event, &enrichment{}); err != nil {
+		log.Printf("Unable to notify %s on node %s: %v", event.Reason, node.Name, err)
+	}
+}
+
+// nodeEvent builds the synthetic event describing a condition transition.
+func nodeEvent(node *v1.Node, condition v1.NodeCondition, eventType string) *v1.Event {
+	reason := "Node" + string(condition.Type)
+	if condition.Type == v1.NodeReady {
+		reason = "NodeNotReady"
+	}
+	message := fmt.Sprintf("%s is %s", condition.Type, condition.Status)
+	if eventType == "Normal" {
+		reason = recoveryReason
+		message = fmt.Sprintf("Recovered, %s is %s", condition.Type, condition.Status)
+	}
+	if condition.Message != "" {
+		message += ": " + condition.Message
+	}
+	now := unversioned.Now()
+	return &v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Node", Name: node.Name, UID: node.UID},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// Notifier delivers a notification about an event to one backend.
+type Notifier interface {
+	Name() string
+	Notify(event *v1.Event, extra *enrichment) error
+}
+
+var sinkNotifications = newCounterVec("sink_notifications_total", "Notification attempts by backend and outcome.", "sink", "result")
+
+// notifySink notifies one backend, accounting for the outcome.
+func notifySink(n Notifier, event *v1.Event, extra *enrichment) error {
+	err := n.Notify(event, extra)
+	if err != nil {
+		sinkNotifications.inc(n.Name(), "failure")
+	} else {
+		sinkNotifications.inc(n.Name(), "success")
+	}
+	recordSend(err)
+	return err
+}
+
+// multiNotifier fans a notification out to every backend, attempting all of
+// them even if some fail.
+type multiNotifier []Notifier
+
+func (m multiNotifier) Name() string {
+	names := make([]string, len(m))
+	for i, n := range m {
+		names[i] = n.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (m multiNotifier) Notify(event *v1.Event, extra *enrichment) error {
+	var failed []string
+	for _, n := range m {
+		if err := notifySink(n, event, extra); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", n.Name(), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// deliver notifies every backend that has not delivered key yet. Successes
+// are deduplicated per backend, so a backend that failed is retried on the
+// next occurrence without repeating the notification on the others. With an
+// outbox, failed notifications are queued and retried from there instead.
+//
+// A send that exceeded NOTIFY_TIMEOUT may still complete, so rather than
+// being retried it is kept as a dead letter and stays claimed.
+func (m multiNotifier) deliver(key string, event *v1.Event, extra *enrichment) error {
+	var failed []string
+	for _, n := range m {
+		sinkKey := key + "\x00" + n.Name()
+		if dedup != nil && !dedup.claim(sinkKey) {
+			continue
+		}
+		err := notifySinkWithin(n, event, extra)
+		if err == errNotifyTimeout {
+			log.Printf("The %s notification of %s exceeded NOTIFY_TIMEOUT", n.Name(), key)
+			if outboxes != nil {
+				if err := outboxes.deadLetter(n.Name(), key, event, extra); err != nil {
+					log.Printf("Unable to keep the %s notification of %s as a dead letter: %v", n.Name(), key, err)
+				}
+			}
+			failed = append(failed, fmt.Sprintf("%s: %v", n.Name(), err))
+			recordDecision(event, "timed-out", n.Name(), key)
+			continue
+		}
+		if err != nil && outboxes != nil {
+			queueErr := outboxes.enqueue(n.Name(), key, event, extra)
+			if queueErr == nil {
+				log.Printf("Queued the %s notification of %s for retry: %v", n.Name(), key, err)
+				recordDecision(event, "queued", n.Name(), key)
+				continue
+			}
+			log.Printf("Unable to queue the %s notification of %s: %v", n.Name(), key, queueErr)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", n.Name(), err))
+			recordDecision(event, "failed", n.Name(), key)
+			if dedup != nil {
+				dedup.release(sinkKey)
+			}
+			continue
+		}
+		recordDecision(event, "sent", n.Name(), key)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// delivered reports whether every backend already delivered key.
+func (m multiNotifier) delivered(key string) bool {
+	if dedup == nil {
+		return false
+	}
+	for _, n := range m {
+		if !dedup.suppressed(key + "\x00" + n.Name()) {
+			return false
+		}
+	}
+	return true
+}
+
+// wouldSuppress is delivered without side effects on the dedup cache.
+func (m multiNotifier) wouldSuppress(key string) bool {
+	if dedup == nil {
+		return false
+	}
+	for _, n := range m {
+		if !dedup.peek(key + "\x00" + n.Name()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m multiNotifier) recordDelivered(key string) {
+	if dedup == nil {
+		return
+	}
+	for _, n := range m {
+		dedup.record(key + "\x00" + n.Name())
+	}
+}
+
+type slackNotifier struct{}
+
+func (slackNotifier) Name() string { return "slack" }
+
+func (slackNotifier) Notify(event *v1.Event, extra *enrichment) error {
+	return notifySlack(event, extra)
+}
+
+// stdoutNotifier writes the Slack payload of every notification to the pod
+// log, one JSON document per line.
+type stdoutNotifier struct{}
+
+func (stdoutNotifier) Name() string { return "stdout" }
+
+func (stdoutNotifier) Notify(event *v1.Event, extra *enrichment) error {
+	return json.NewEncoder(os.Stdout).Encode(buildSlackMessage("stdout", event, extra))
+}
+
+var notifier multiNotifier
+
+// newNotifier builds the backends listed in NOTIFY_TARGETS, Slack by
+// default. MIRROR_STDOUT additionally logs every notification.
+func newNotifier() (multiNotifier, error) {
+	var notifiers multiNotifier
+	for _, target := range cfg.NotifyTargets {
+		switch target {
+		case "slack":
+			if currentBotToken() != "" {
+				if cfg.SlackChannel == "" && currentRouting() == nil {
+					return nil, fmt.Errorf("SLACK_BOT_TOKEN requires SLACK_CHANNEL or SLACK_DESTINATIONS")
+				}
+			} else if currentWebhooks() == nil && currentRouting() == nil {
+				return nil, fmt.Errorf("no Slack webhook configured, set SLACK_WEBHOOK_URL, SLACK_WEBHOOK_URLS or SLACK_DESTINATIONS")
+			}
+			notifiers = append(notifiers, slackNotifier{})
+		case "webhook":
+			if cfg.GenericWebhookURL == "" {
+				return nil, fmt.Errorf("the webhook target requires GENERIC_WEBHOOK_URL")
+			}
+			notifiers = append(notifiers, webhookNotifier{url: cfg.GenericWebhookURL, gzip: cfg.WebhookGzip})
+		case "unix":
+			if cfg.UnixSocketPath == "" {
+				return nil, fmt.Errorf("the unix target requires UNIX_SOCKET_PATH")
+			}
+			notifiers = append(notifiers, &socketNotifier{path: cfg.UnixSocketPath})
+		case "googlechat":
+			if cfg.GoogleChatWebhookURL == "" {
+				return nil, fmt.Errorf("the googlechat target requires GOOGLE_CHAT_WEBHOOK_URL")
+			}
+			notifiers = append(notifiers, googleChatNotifier{url: cfg.GoogleChatWebhookURL})
+		case "stdout":
+			notifiers = append(notifiers, stdoutNotifier{})
+		default:
+			return nil, fmt.Errorf("unknown notify target %q", target)
+		}
+	}
+	if cfg.MirrorStdout {
+		notifiers = append(notifiers, stdoutNotifier{})
+	}
+	if len(notifiers) == 0 {
+		return nil, fmt.Errorf("no notify targets configured")
+	}
+	return notifiers, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// withSlackWebhook points the default webhook pool at a server answering
+// every post with status.
+func withSlackWebhook(t *testing.T, status int) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	saved := webhooks
+	webhooks = &webhookPool{urls: []string{server.URL}, weights: []int{1}, total: 1, strategy: "weighted"}
+	t.Cleanup(func() {
+		webhooks = saved
+		server.Close()
+	})
+}
+
+// withNotifier sets the notified backends for the duration of the test.
+func withNotifier(t testing.TB, m multiNotifier) {
+	saved := notifier
+	notifier = m
+	t.Cleanup(func() { notifier = saved })
+}
+
+// counterValue reads a counter of the vector, 0 when never incremented.
+func counterValue(c *counterVec, labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.values[strings.Join(labelValues, "\xff")]; ok {
+		return v.value
+	}
+	return 0
+}
+
+func TestSinkNotificationsCountSlackStatus(t *testing.T) {
+	withConfig(t, &Config{SlackFormat: "legacy"})
+	event := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+
+	for _, test := range []struct {
+		status int
+		result string
+	}{
+		{http.StatusOK, "success"},
+		{http.StatusForbidden, "failure"},
+		{http.StatusInternalServerError, "failure"},
+	} {
+		withSlackWebhook(t, test.status)
+		before := counterValue(sinkNotifications, "slack", test.result)
+		notifySink(slackNotifier{}, event, &enrichment{})
+		if got := counterValue(sinkNotifications, "slack", test.result); got != before+1 {
+			t.Errorf("status %d: %s count went from %v to %v", test.status, test.result, before, got)
+		}
+	}
+}
+
+// fakeSink records notifications, failing while fail is set.
+type fakeSink struct {
+	name     string
+	fail     bool
+	notified int
+	events   []*v1.Event
+}
+
+func (s *fakeSink) Name() string { return s.name }
+
+func (s *fakeSink) Notify(event *v1.Event, extra *enrichment) error {
+	if s.fail {
+		return errors.New("unavailable")
+	}
+	s.notified++
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestDeliverPartialFailure(t *testing.T) {
+	withConfig(t, &Config{})
+	saved := dedup
+	dedup = newDedupCache(time.Hour, 0, nil)
+	t.Cleanup(func() { dedup = saved })
+
+	healthy, flaky := &fakeSink{name: "webhook"}, &fakeSink{name: "unix", fail: true}
+	sinks := multiNotifier{healthy, flaky}
+	event := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+	key := dedupKey(event)
+
+	if err := sinks.deliver(key, event, &enrichment{}); err == nil {
+		t.Fatal("a failed sink was not reported")
+	}
+	if sinks.delivered(key) {
+		t.Fatal("the event counts as delivered although a sink failed")
+	}
+	flaky.fail = false
+	if err := sinks.deliver(key, event, &enrichment{}); err != nil {
+		t.Fatal(err)
+	}
+	if healthy.notified != 1 || flaky.notified != 1 {
+		t.Errorf("notified %d and %d times, want each sink once", healthy.notified, flaky.notified)
+	}
+	if !sinks.delivered(key) {
+		t.Error("the event is not delivered once every sink succeeded")
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// outbox persists notifications a backend failed to deliver in OUTBOX_DIR,
+// one JSON file each, and retries them in the background with exponential
+// backoff until they succeed or exceed OUTBOX_MAX_AGE. Being on disk, the
+// queue survives restarts when the directory is a persistent volume.
+//
+// Notifications given up on are kept as dead letters in the "dead"
+// subdirectory for inspection until DEAD_LETTER_MAX_AGE has passed since
+// their last attempt, and can be replayed through the outbox.
+//
+// Items hold the event and its enrichment rather than a rendered payload,
+// so a retry or replay renders the notification with the configuration
+// current at that time.
+type outbox struct {
+	dir           string
+	interval      time.Duration
+	maxAge        time.Duration
+	deadLetterAge time.Duration
+
+	mu    sync.Mutex
+	seq   int
+	items map[string]*outboxItem
+}
+
+// outboxVersion stamps the items written, so the items of other releases
+// can be told apart on replay. Items of version 0 predate the stamp and
+// share the layout of version 1.
+const outboxVersion = 1
+
+// outboxItem is a pending notification to one backend.
+type outboxItem struct {
+	Version     int         `json:"version"`
+	Sink        string      `json:"sink"`
+	Key         string      `json:"key"`
+	Event       *v1.Event   `json:"event"`
+	Extra       *enrichment `json:"extra"`
+	Enqueued    time.Time   `json:"enqueued"`
+	Attempts    int         `json:"attempts"`
+	NextAttempt time.Time   `json:"nextAttempt"`
+}
+
+// outboxMaxBackoff caps the delay between two attempts of an item.
+const outboxMaxBackoff = time.Hour
+
+var (
+	outboxes *outbox
+
+	outboxDelivered = newCounterVec("outbox_delivered_total", "Queued notifications delivered on retry.", "sink")
+	outboxDropped   = newCounterVec("outbox_dropped_total", "Queued notifications given up on and moved to the dead letters.", "sink")
+	deadLetterDrops = newCounterVec("dead_letters_expired_total", "Dead letters permanently deleted after DEAD_LETTER_MAX_AGE.")
+	outboxDepth     = newGaugeFunc("outbox_depth", "Notifications waiting in the outbox.", func() float64 {
+		if outboxes == nil {
+			return 0
+		}
+		depth, _ := outboxes.stats()
+		return float64(depth)
+	})
+	outboxOldestAge = newGaugeFunc("outbox_oldest_age_seconds", "Age of the oldest notification waiting in the outbox.", func() float64 {
+		if outboxes == nil {
+			return 0
+		}
+		_, oldest := outboxes.stats()
+		return oldest.Seconds()
+	})
+)
+
+// newOutbox opens the outbox directory, picking up the items left by a
+// previous run.
+func newOutbox(dir string, interval, maxAge, deadLetterAge time.Duration) (*outbox, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "dead"), 0700); err != nil {
+		return nil, err
+	}
+	o := &outbox{dir: dir, interval: interval, maxAge: maxAge, deadLetterAge: deadLetterAge, items: map[string]*outboxItem{}}
+	o.expireDeadLetters(time.Now())
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		item := &outboxItem{}
+		if err := json.Unmarshal(content, item); err != nil {
+			log.Printf("Discarding unreadable outbox item %s: %v", file.Name(), err)
+			os.Remove(filepath.Join(dir, file.Name()))
+			continue
+		}
+		o.items[file.Name()] = item
+	}
+	if len(o.items) > 0 {
+		log.Printf("Resuming %d queued notifications from %s", len(o.items), dir)
+	}
+	return o, nil
+}
+
+// enqueue persists a notification the sink failed to deliver.
+func (o *outbox) enqueue(sink, key string, event *v1.Event, extra *enrichment) error {
+	now := time.Now()
+	item := &outboxItem{
+		Version:     outboxVersion,
+		Sink:        sink,
+		Key:         key,
+		Event:       event,
+		Extra:       extra,
+		Enqueued:    now,
+		Attempts:    1,
+		NextAttempt: now.Add(o.interval),
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.seq++
+	name := fmt.Sprintf("%020d-%d.json", now.UnixNano(), o.seq)
+	if err := o.write(name, item); err != nil {
+		return err
+	}
+	o.items[name] = item
+	return nil
+}
+
+// deadLetter keeps a notification as a dead letter right away, for sends
+// that timed out and might have been delivered after all.
+func (o *outbox) deadLetter(sink, key string, event *v1.Event, extra *enrichment) error {
+	now := time.Now()
+	item := &outboxItem{Version: outboxVersion, Sink: sink, Key: key, Event: event, Extra: extra, Enqueued: now, Attempts: 1}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.seq++
+	name := fmt.Sprintf("%020d-%d.json", now.UnixNano(), o.seq)
+	if err := o.write(name, item); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(o.dir, name), filepath.Join(o.dir, "dead", name))
+}
+
+// write saves the item atomically, so a crash never leaves a torn file.
+func (o *outbox) write(name string, item *outboxItem) error {
+	content, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(o.dir, "."+name)
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(o.dir, name))
+}
+
+func (o *outbox) remove(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.items, name)
+	if err := os.Remove(filepath.Join(o.dir, name)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Unable to remove outbox item %s: %v", name, err)
+	}
+}
+
+// stats returns the number of queued items and the age of the oldest.
+func (o *outbox) stats() (int, time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var oldest time.Duration
+	for _, item := range o.items {
+		if age := time.Since(item.Enqueued); age > oldest {
+			oldest = age
+		}
+	}
+	return len(o.items), oldest
+}
+
+// bury moves an item given up on to the dead letters.
+func (o *outbox) bury(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.items, name)
+	if err := os.Rename(filepath.Join(o.dir, name), filepath.Join(o.dir, "dead", name)); err != nil {
+		log.Printf("Unable to move outbox item %s to the dead letters: %v", name, err)
+	}
+}
+
+// expireDeadLetters deletes the dead letters whose last attempt is older
+// than DEAD_LETTER_MAX_AGE.
+func (o *outbox) expireDeadLetters(now time.Time) {
+	dir := filepath.Join(o.dir, "dead")
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Printf("Unable to list the dead letters: %v", err)
+		return
+	}
+	for _, file := range files {
+		if file.IsDir() || now.Sub(file.ModTime()) <= o.deadLetterAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, file.Name())); err != nil {
+			log.Printf("Unable to delete dead letter %s: %v", file.Name(), err)
+			continue
+		}
+		deadLetterDrops.inc()
+	}
+}
+
+// replayDeadLetters moves the dead letters back to the outbox to be retried
+// from scratch. Items of an older version are upgraded, those of a newer
+// release or unreadable ones are left in the dead letters.
+func (o *outbox) replayDeadLetters() (replayed, skipped int, err error) {
+	dir := filepath.Join(o.dir, "dead")
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	now := time.Now()
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return replayed, skipped, err
+		}
+		item := &outboxItem{}
+		if err := json.Unmarshal(content, item); err != nil || item.Event == nil {
+			log.Printf("Not replaying unreadable dead letter %s: %v", file.Name(), err)
+			skipped++
+			continue
+		}
+		if item.Version > outboxVersion {
+			log.Printf("Not replaying dead letter %s of version %d, this release reads up to version %d", file.Name(), item.Version, outboxVersion)
+			skipped++
+			continue
+		}
+		item.Version = outboxVersion
+		if item.Extra == nil {
+			item.Extra = &enrichment{}
+		}
+		item.Enqueued, item.Attempts, item.NextAttempt = now, 0, now
+
+		o.mu.Lock()
+		err = o.write(file.Name(), item)
+		if err == nil {
+			o.items[file.Name()] = item
+		}
+		o.mu.Unlock()
+		if err != nil {
+			return replayed, skipped, err
+		}
+		if err := os.Remove(filepath.Join(dir, file.Name())); err != nil {
+			log.Printf("Unable to remove replayed dead letter %s: %v", file.Name(), err)
+		}
+		replayed++
+	}
+	return replayed, skipped, nil
+}
+
+// replayHandler replays the dead letters on POST, reporting how many were
+// replayed and skipped.
+func replayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST to replay the dead letters", http.StatusMethodNotAllowed)
+		return
+	}
+	if outboxes == nil {
+		http.Error(w, "OUTBOX_DIR is not set", http.StatusNotFound)
+		return
+	}
+	replayed, skipped, err := outboxes.replayDeadLetters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Replayed %d dead letters, skipped %d", replayed, skipped)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"replayed": replayed, "skipped": skipped})
+}
+
+func (o *outbox) run() {
+	for range time.Tick(o.interval) {
+		now := time.Now()
+		o.retry(now)
+		o.expireDeadLetters(now)
+	}
+}
+
+// retry attempts every due item once, burying those past their max age.
+func (o *outbox) retry(now time.Time) {
+	o.mu.Lock()
+	due := map[string]*outboxItem{}
+	for name, item := range o.items {
+		if !now.Before(item.NextAttempt) {
+			due[name] = item
+		}
+	}
+	o.mu.Unlock()
+
+	for name, item := range due {
+		if now.Sub(item.Enqueued) > o.maxAge {
+			log.Printf("Giving up on the %s notification of %s after %d attempts", item.Sink, item.Key, item.Attempts)
+			outboxDropped.inc(item.Sink)
+			o.bury(name)
+			continue
+		}
+		sink := notifierNamed(item.Sink)
+		if sink == nil {
+			log.Printf("Giving up on the %s notification of %s, the backend is no longer configured", item.Sink, item.Key)
+			outboxDropped.inc(item.Sink)
+			o.bury(name)
+			continue
+		}
+		if err := notifySink(sink, item.Event, item.Extra); err != nil {
+			item.Attempts++
+			item.NextAttempt = now.Add(outboxBackoff(o.interval, item.Attempts))
+			o.mu.Lock()
+			if err := o.write(name, item); err != nil {
+				log.Printf("Unable to update outbox item %s: %v", name, err)
+			}
+			o.mu.Unlock()
+			continue
+		}
+		outboxDelivered.inc(item.Sink)
+		o.remove(name)
+	}
+}
+
+// outboxBackoff doubles the retry interval with every attempt.
+func outboxBackoff(interval time.Duration, attempts int) time.Duration {
+	backoff := interval
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	return backoff
+}
+
+func notifierNamed(name string) Notifier {
+	for _, n := range notifier {
+		if n.Name() == name {
+			return n
+		}
+	}
+	return nil
+}
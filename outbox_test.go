@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// withOutbox sets up an outbox in a temporary directory.
+func withOutbox(t *testing.T) *outbox {
+	o, err := newOutbox(t.TempDir(), time.Minute, time.Hour, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := outboxes
+	outboxes = o
+	t.Cleanup(func() { outboxes = saved })
+	return o
+}
+
+func TestDeliverQueuesSlackErrorStatus(t *testing.T) {
+	withConfig(t, &Config{SlackFormat: "legacy"})
+	o := withOutbox(t)
+	event := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+
+	withSlackWebhook(t, http.StatusInternalServerError)
+	if err := (multiNotifier{slackNotifier{}}).deliver(dedupKey(event), event, &enrichment{}); err != nil {
+		t.Fatalf("a queued notification failed: %v", err)
+	}
+	if queued, _ := o.stats(); queued != 1 {
+		t.Fatalf("%d notifications queued, want 1", queued)
+	}
+
+	saved := notifier
+	notifier = multiNotifier{slackNotifier{}}
+	t.Cleanup(func() { notifier = saved })
+	withSlackWebhook(t, http.StatusOK)
+	before := counterValue(outboxDelivered, "slack")
+	o.retry(time.Now().Add(time.Minute))
+	if queued, _ := o.stats(); queued != 0 {
+		t.Errorf("%d notifications still queued after a successful retry", queued)
+	}
+	if counterValue(outboxDelivered, "slack") != before+1 {
+		t.Error("the retry was not delivered")
+	}
+}
+
+// writeDeadLetter stores content as a dead letter of o.
+func writeDeadLetter(t *testing.T, o *outbox, name, content string) {
+	if err := ioutil.WriteFile(filepath.Join(o.dir, "dead", name), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayUpgradesUnstampedDeadLetters(t *testing.T) {
+	o := withOutbox(t)
+	// Written by a release predating the version stamp and extra.
+	writeDeadLetter(t, o, "1-1.json", `{"sink": "slack", "key": "app/Pod/web-1", "event": {"reason": "BackOff"}, "attempts": 7}`)
+
+	replayed, skipped, err := o.replayDeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 1 || skipped != 0 {
+		t.Fatalf("replayed %d and skipped %d, want 1 and 0", replayed, skipped)
+	}
+	item := o.items["1-1.json"]
+	if item == nil {
+		t.Fatal("the dead letter was not queued")
+	}
+	if item.Version != outboxVersion || item.Extra == nil || item.Attempts != 0 || item.Event.Reason != "BackOff" {
+		t.Errorf("replayed item not upgraded: %+v", item)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(o.dir, "1-1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := &outboxItem{}
+	if err := json.Unmarshal(content, stored); err != nil || stored.Version != outboxVersion {
+		t.Errorf("the queued file was not rewritten with version %d: %s", outboxVersion, content)
+	}
+	if _, err := os.Stat(filepath.Join(o.dir, "dead", "1-1.json")); !os.IsNotExist(err) {
+		t.Error("the replayed dead letter was not removed")
+	}
+}
+
+func TestReplaySkipsNewerDeadLetters(t *testing.T) {
+	o := withOutbox(t)
+	writeDeadLetter(t, o, "1-1.json", fmt.Sprintf(`{"version": %d, "sink": "slack", "event": {"reason": "BackOff"}}`, outboxVersion+1))
+
+	replayed, skipped, err := o.replayDeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 0 || skipped != 1 {
+		t.Fatalf("replayed %d and skipped %d, want 0 and 1", replayed, skipped)
+	}
+	if len(o.items) != 0 {
+		t.Error("a dead letter of a newer release was queued")
+	}
+	if _, err := os.Stat(filepath.Join(o.dir, "dead", "1-1.json")); err != nil {
+		t.Errorf("the skipped dead letter was not kept: %v", err)
+	}
+}
+
+func TestExpireDeadLetters(t *testing.T) {
+	o := withOutbox(t)
+	now := time.Now()
+	writeDeadLetter(t, o, "old.json", `{"sink": "slack"}`)
+	writeDeadLetter(t, o, "recent.json", `{"sink": "slack"}`)
+	old := now.Add(-o.deadLetterAge - time.Minute)
+	if err := os.Chtimes(filepath.Join(o.dir, "dead", "old.json"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	before := counterValue(deadLetterDrops)
+	o.expireDeadLetters(now)
+	if _, err := os.Stat(filepath.Join(o.dir, "dead", "old.json")); !os.IsNotExist(err) {
+		t.Error("a dead letter past DEAD_LETTER_MAX_AGE was kept")
+	}
+	if _, err := os.Stat(filepath.Join(o.dir, "dead", "recent.json")); err != nil {
+		t.Errorf("a recent dead letter was deleted: %v", err)
+	}
+	if got := counterValue(deadLetterDrops); got != before+1 {
+		t.Errorf("%v dead letters counted as expired, want 1", got-before)
+	}
+}
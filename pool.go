@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// webhookPool spreads POSTs over several Slack webhooks pointing at the same
+// channel so storms are not throttled by Slack's per-webhook rate limit.
+type webhookPool struct {
+	urls     []string
+	weights  []int
+	total    int
+	strategy string
+
+	mu   sync.Mutex
+	next int
+}
+
+var (
+	webhooksMu sync.RWMutex
+	webhooks   *webhookPool
+)
+
+func currentWebhooks() *webhookPool {
+	webhooksMu.RLock()
+	defer webhooksMu.RUnlock()
+	return webhooks
+}
+
+// loadWebhooks (re)builds the webhook pool, keeping the previous one if the
+// new configuration is invalid. The pool stays nil if no webhook is set.
+func loadWebhooks() error {
+	pool, err := newWebhookPool()
+	if err != nil {
+		return err
+	}
+	webhooksMu.Lock()
+	webhooks = pool
+	webhooksMu.Unlock()
+	return nil
+}
+
+// newWebhookPool reads SLACK_WEBHOOK_URLS, a comma separated list of webhook
+// URLs each optionally suffixed with "#<weight>", falling back to the single
+// SLACK_WEBHOOK_URL. Both can instead be read from a mounted file named by
+// SLACK_WEBHOOK_URLS_FILE or SLACK_WEBHOOK_URL_FILE. WEBHOOK_POOL_STRATEGY
+// selects "weighted" (the default) or "round-robin", which ignores weights.
+func newWebhookPool() (*webhookPool, error) {
+	pool := &webhookPool{strategy: os.Getenv("WEBHOOK_POOL_STRATEGY")}
+	if pool.strategy == "" {
+		pool.strategy = "weighted"
+	}
+	if pool.strategy != "weighted" && pool.strategy != "round-robin" {
+		return nil, fmt.Errorf("unknown WEBHOOK_POOL_STRATEGY %q", pool.strategy)
+	}
+
+	entries, err := secretEnv("SLACK_WEBHOOK_URLS")
+	if err != nil {
+		return nil, err
+	}
+	if entries == "" {
+		entries, err = secretEnv("SLACK_WEBHOOK_URL")
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, entry := range strings.Split(entries, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		url, weight := entry, 1
+		if i := strings.LastIndex(entry, "#"); i >= 0 {
+			w, err := strconv.Atoi(entry[i+1:])
+			if err != nil || w < 1 {
+				return nil, fmt.Errorf("invalid webhook weight in %q", entry)
+			}
+			url, weight = entry[:i], w
+		}
+		if u, err := neturl.Parse(url); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid Slack webhook %q", url)
+		}
+		pool.urls = append(pool.urls, url)
+		pool.weights = append(pool.weights, weight)
+		pool.total += weight
+	}
+	if len(pool.urls) == 0 {
+		return nil, nil
+	}
+	return pool, nil
+}
+
+// pick returns the webhook the next message should be posted to.
+func (p *webhookPool) pick() string {
+	if len(p.urls) == 1 {
+		return p.urls[0]
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.strategy == "round-robin" {
+		url := p.urls[p.next]
+		p.next = (p.next + 1) % len(p.urls)
+		return url
+	}
+
+	n := rand.Intn(p.total)
+	for i, weight := range p.weights {
+		if n < weight {
+			return p.urls[i]
+		}
+		n -= weight
+	}
+	return p.urls[len(p.urls)-1]
+}
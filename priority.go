@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// priority is how urgently an event is notified:
+//
+//	low     posted quietly, without any mention, or to the digest when
+//	        DIGEST_INTERVAL is set
+//	normal  posted as configured, the default
+//	high    posted with SLACK_MENTION on every occurrence, bypassing
+//	        deduplication, and never held back by the startup grace,
+//	        business hours, digests or batches
+//
+// Storm detection and throttling apply whatever the priority.
+type priority int
+
+const (
+	priorityLow priority = iota
+	priorityNormal
+	priorityHigh
+)
+
+var priorityNames = map[string]priority{"low": priorityLow, "normal": priorityNormal, "high": priorityHigh}
+
+// parsePriorities parses the severity=priority pairs of PRIORITIES.
+func parsePriorities(pairs map[string]string) (map[string]priority, error) {
+	priorities := map[string]priority{}
+	for severity, name := range pairs {
+		if severityRank(severity) < 0 {
+			return nil, fmt.Errorf("unknown severity %q, use %s", severity, strings.Join(severities, ", "))
+		}
+		p, ok := priorityNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown priority %q, use low, normal or high", name)
+		}
+		priorities[severity] = p
+	}
+	return priorities, nil
+}
+
+// priorityKey extends the dedup key of a high priority event with its
+// occurrence, so only the same occurrence delivered again, e.g. after the
+// watch restarted, is suppressed.
+func priorityKey(key string, event *v1.Event, p priority) string {
+	if p != priorityHigh {
+		return key
+	}
+	return fmt.Sprintf("%s/%s/%d", key, event.UID, event.Count)
+}
+
+// priorityOf maps the event's severity to its priority.
+func priorityOf(event *v1.Event) priority {
+	if p, ok := cfg.Priorities[severityOf(event)]; ok {
+		return p
+	}
+	return priorityNormal
+}
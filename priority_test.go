@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/types"
+)
+
+func TestPriorityMention(t *testing.T) {
+	rules, err := parseRoutingRules(`[{"namespace": "prod", "mention": "<@oncall>"}]`, []string{"slack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	priorities := map[string]priority{"info": priorityLow, "warning": priorityNormal, "critical": priorityHigh}
+	withConfig(t, &Config{Priorities: priorities, CriticalReasons: []string{"OOMKilling"}, NotifyRules: rules, SlackMention: "<!here>"})
+
+	for _, test := range []struct {
+		name  string
+		event *v1.Event
+		want  string
+	}{
+		{"low priority with a rule mention", normalEvent("prod", "Pulled"), ""},
+		{"normal priority with a rule mention", testEvent("prod", "Pod", "web-1", "BackOff", ""), "<@oncall>"},
+		{"normal priority", testEvent("dev", "Pod", "web-1", "BackOff", ""), ""},
+		{"high priority", testEvent("dev", "Pod", "web-1", "OOMKilling", ""), "<!here>"},
+	} {
+		if got := buildSlackMessage("slack", test.event, &enrichment{}).Text; got != test.want {
+			t.Errorf("%s: mention %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHighPriorityBypassesDedup(t *testing.T) {
+	withConfig(t, &Config{Priorities: map[string]priority{"critical": priorityHigh}, CriticalReasons: []string{"OOMKilling"}})
+	sink := &fakeSink{name: "slack"}
+	withNotifier(t, multiNotifier{sink})
+	saved := dedup
+	dedup = newDedupCache(time.Hour, 0, nil)
+	t.Cleanup(func() { dedup = saved })
+
+	for _, reason := range []string{"OOMKilling", "BackOff"} {
+		sink.notified = 0
+		for _, count := range []int32{1, 2, 2} {
+			event := testEvent("app", "Pod", "web-1", reason, "Container exceeded its memory limit")
+			event.UID, event.Count = types.UID("uid-"+reason), count
+			handleEvent(nil, event)
+		}
+		want := map[string]int{"OOMKilling": 2, "BackOff": 1}[reason]
+		if sink.notified != want {
+			t.Errorf("%s notified %d times, want %d", reason, sink.notified, want)
+		}
+	}
+}
+
+func TestLowPriorityIsDigested(t *testing.T) {
+	saved := digests
+	digests = newDigest("namespace+reason")
+	t.Cleanup(func() { digests = saved })
+
+	withConfig(t, &Config{DigestNamespaces: []string{"ci-*"}, Priorities: map[string]priority{"info": priorityLow}})
+	for _, test := range []struct {
+		namespace, eventType string
+		want                 bool
+	}{
+		{"prod", "Normal", true},
+		{"prod", "Warning", false},
+		{"ci-42", "Warning", true},
+	} {
+		event := testEvent(test.namespace, "Pod", "web-1", "BackOff", "")
+		event.Type = test.eventType
+		if got := digested(nil, event, priorityOf(event)); got != test.want {
+			t.Errorf("%s %s event digested %v, want %v", test.namespace, test.eventType, got, test.want)
+		}
+	}
+}
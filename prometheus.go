@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+type prometheusAlert struct {
+	Labels map[string]string `json:"labels"`
+	State  string            `json:"state"`
+}
+
+type prometheusAlertsResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Alerts []prometheusAlert `json:"alerts"`
+	} `json:"data"`
+}
+
+var (
+	prometheusClient = &http.Client{Timeout: 5 * time.Second}
+	alertsCache      = newLRUCache("prometheus-alerts", 30*time.Second, 1)
+)
+
+// firingAlerts returns the names of the alerts firing in PROMETHEUS_URL for
+// the event's namespace, and for its pod when the event is about one. The
+// alert list is fetched at most once per cache TTL.
+func firingAlerts(event *v1.Event) ([]string, error) {
+	cached, err := alertsCache.fetch("alerts", withEnrichmentTimeout(func() (interface{}, error) {
+		return fetchAlerts(cfg.PrometheusURL)
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var names []string
+	for _, alert := range cached.([]prometheusAlert) {
+		if alert.State != "firing" || alert.Labels["namespace"] != event.InvolvedObject.Namespace {
+			continue
+		}
+		if pod, ok := alert.Labels["pod"]; ok && event.InvolvedObject.Kind == "Pod" && pod != event.InvolvedObject.Name {
+			continue
+		}
+		if name := alert.Labels["alertname"]; !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func fetchAlerts(prometheusURL string) ([]prometheusAlert, error) {
+	resp, err := prometheusClient.Get(strings.TrimRight(prometheusURL, "/") + "/api/v1/alerts")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prometheus responded %s", resp.Status)
+	}
+	var alerts prometheusAlertsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&alerts); err != nil {
+		return nil, err
+	}
+	return alerts.Data.Alerts, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// recorder records the notifier's activity as events on the objects it
+// notified about, with EMIT_EVENTS. nil when disabled.
+var recorder record.EventRecorder
+
+// currentEventSink creates the recorded events with the current clientset,
+// so they survive it being rebuilt after a credential rotation.
+type currentEventSink struct{}
+
+func (currentEventSink) sink() *v1core.EventSinkImpl {
+	return &v1core.EventSinkImpl{Interface: currentClientset().CoreV1().Events("")}
+}
+
+func (s currentEventSink) Create(event *v1.Event) (*v1.Event, error) {
+	return s.sink().Create(event)
+}
+
+func (s currentEventSink) Update(event *v1.Event) (*v1.Event, error) {
+	return s.sink().Update(event)
+}
+
+func (s currentEventSink) Patch(event *v1.Event, data []byte) (*v1.Event, error) {
+	return s.sink().Patch(event, data)
+}
+
+func startRecorder() {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(currentEventSink{})
+	recorder = broadcaster.NewRecorder(v1.EventSource{Component: eventComponent})
+}
+
+// recordEvent records a notification decision about the event as an event
+// on its object: a warning when the notification failed or timed out, and,
+// with EMIT_EVENTS=all, a Normal event when it was sent. Events of the other
+// CLUSTER_CONTEXTS are left alone, the recorder writing to the first one.
+func recordEvent(event *v1.Event, decision, detail string) {
+	if recorder == nil || event.ClusterName != clusterName(currentClientset()) {
+		return
+	}
+	object := event.InvolvedObject
+	switch decision {
+	case "sent":
+		if cfg.EmitEvents == "all" {
+			recorder.Event(&object, "Normal", "NotificationSent", fmt.Sprintf("Sent the %s notification of %s", detail, event.Reason))
+		}
+	case "failed", "timed-out":
+		recorder.Event(&object, "Warning", "NotificationFailed", fmt.Sprintf("The %s notification of %s %s", detail, event.Reason, decision))
+	}
+}
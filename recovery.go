@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// recoveryTracker polls pods that were alerted on and posts a recovery
+// notification once they are running with all containers ready again.
+type recoveryTracker struct {
+	mu      sync.Mutex
+	pending map[string]*v1.Event
+}
+
+func newRecoveryTracker() *recoveryTracker {
+	return &recoveryTracker{pending: map[string]*v1.Event{}}
+}
+
+// track remembers the alert so its pod can be checked for recovery in the
+// cluster it was reported by. Only pods are tracked as other kinds have no
+// uniform notion of health.
+func (t *recoveryTracker) track(event *v1.Event) {
+	if event.Type != "Warning" || event.InvolvedObject.Kind != "Pod" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[event.ClusterName+"/"+event.InvolvedObject.Namespace+"/"+event.InvolvedObject.Name] = event
+}
+
+func (t *recoveryTracker) run(interval time.Duration) {
+	for range time.Tick(interval) {
+		t.check()
+	}
+}
+
+// check notifies the recovery of the pods that are healthy again. Alerts
+// are kept until their pod recovered or was deleted, so an API error only
+// delays the recovery to the next check.
+func (t *recoveryTracker) check() {
+	t.mu.Lock()
+	pending := make(map[string]*v1.Event, len(t.pending))
+	for key, event := range t.pending {
+		pending[key] = event
+	}
+	t.mu.Unlock()
+
+	for key, alert := range pending {
+		pod, err := clusterClientset(alert.ClusterName).CoreV1().Pods(alert.InvolvedObject.Namespace).Get(alert.InvolvedObject.Name)
+		if apierrors.IsNotFound(err) {
+			// The pod is gone, there is nothing to recover.
+			t.forget(key, alert)
+			continue
+		}
+		if err != nil {
+			log.Printf("Unable to check the recovery of %s, retrying: %v", key, err)
+			continue
+		}
+		if !podHealthy(pod) {
+			continue
+		}
+		recovery := recoveryEvent(alert)
+		if flaps != nil && flaps.held(recovery) {
+			t.forget(key, alert)
+			continue
+		}
+		if err := notifier.Notify(recovery, &enrichment{}); err != nil {
+			log.Printf("Unable to notify the recovery of %s: %v", key, err)
+			continue
+		}
+		t.forget(key, alert)
+	}
+}
+
+// forget drops the alert unless a newer one was tracked in the meantime.
+func (t *recoveryTracker) forget(key string, alert *v1.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending[key] == alert {
+		delete(t.pending, key)
+	}
+}
+
+func podHealthy(pod *v1.Pod) bool {
+	if pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if !status.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// recoveryReason is the reason of the synthetic events announcing that the
+// object of an earlier alert, a pod or a node condition, is healthy again.
+const recoveryReason = "Recovered"
+
+// recoveryEvent builds the synthetic Normal event announcing that the
+// object of an earlier alert is healthy again.
+func recoveryEvent(alert *v1.Event) *v1.Event {
+	now := unversioned.Now()
+	event := &v1.Event{
+		InvolvedObject: alert.InvolvedObject,
+		Type:           "Normal",
+		Reason:         recoveryReason,
+		Message:        fmt.Sprintf("Recovered from %s reported at %s: %s", alert.Reason, alert.LastTimestamp.Format(time.RFC3339), alert.Message),
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	event.ClusterName = alert.ClusterName
+	return event
+}
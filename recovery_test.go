@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// withCluster registers a cluster whose API server answers the lookups of
+// the named pods with their answer, and of any other pod with NotFound.
+func withCluster(t *testing.T, name string, pods map[string]func(w http.ResponseWriter)) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if answer, ok := pods[path.Base(r.URL.Path)]; ok {
+			answer(w)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "NotFound", "code": 404}`))
+	}))
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusterNamesMu.Lock()
+	clusterClients[name] = clientset
+	clusterNamesMu.Unlock()
+	t.Cleanup(func() {
+		clusterNamesMu.Lock()
+		delete(clusterClients, name)
+		clusterNamesMu.Unlock()
+		server.Close()
+	})
+}
+
+func TestRecoveryCheck(t *testing.T) {
+	withConfig(t, &Config{})
+	sink := &fakeSink{name: "slack"}
+	withNotifier(t, multiNotifier{sink})
+	withCluster(t, "east", map[string]func(w http.ResponseWriter){
+		"flaky": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "code": 500}`))
+		},
+		"healthy": func(w http.ResponseWriter) {
+			w.Write([]byte(`{"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "healthy"}, "status": {"phase": "Running", "containerStatuses": [{"ready": true}]}}`))
+		},
+	})
+
+	tracker := newRecoveryTracker()
+	for _, name := range []string{"flaky", "gone", "healthy"} {
+		alert := testEvent("app", "Pod", name, "BackOff", "Back-off restarting failed container")
+		alert.ClusterName = "east"
+		tracker.track(alert)
+	}
+	tracker.check()
+
+	if _, ok := tracker.pending["east/app/flaky"]; !ok {
+		t.Error("the alert was forgotten on an API error")
+	}
+	if _, ok := tracker.pending["east/app/gone"]; ok {
+		t.Error("the alert of a deleted pod was kept")
+	}
+	if _, ok := tracker.pending["east/app/healthy"]; ok {
+		t.Error("the alert of a recovered pod was kept")
+	}
+	if len(sink.events) != 1 || sink.events[0].InvolvedObject.Name != "healthy" || sink.events[0].ClusterName != "east" {
+		t.Errorf("notified %d recoveries, want only that of the healthy pod of cluster east", len(sink.events))
+	}
+}
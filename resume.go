@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// resumePoint is where a watch ended: the resourceVersion of the last event
+// it received, and the time it started notifying events after.
+type resumePoint struct {
+	resourceVersion string
+	since           time.Time
+}
+
+var (
+	resumeMu     sync.Mutex
+	resumePoints = map[string]resumePoint{}
+)
+
+// remember records the event as the one to resume the cluster's watch after.
+func remember(clientset *kubernetes.Clientset, event *v1.Event, since time.Time) {
+	if !cfg.WatchResume || event.ResourceVersion == "" {
+		return
+	}
+	resumeMu.Lock()
+	resumePoints[clusterName(clientset)] = resumePoint{resourceVersion: event.ResourceVersion, since: since}
+	resumeMu.Unlock()
+}
+
+// takeResumePoint returns where to resume the cluster's watch, if anywhere,
+// forgetting it so a resourceVersion the server no longer has is only tried
+// once.
+func takeResumePoint(clientset *kubernetes.Clientset) (resumePoint, bool) {
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+	point, ok := resumePoints[clusterName(clientset)]
+	delete(resumePoints, clusterName(clientset))
+	return point, ok
+}
+
+// lastSeenAt is when the event last occurred.
+func lastSeenAt(event *v1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	return occurredAt(event)
+}
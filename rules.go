@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// routingRule sends the events of the namespaces and reasons matching glob
+// patterns and of a severity to one backend, to a channel or named
+// SLACK_DESTINATIONS, with a mention. Empty fields match, or keep the
+// configured, anything.
+type routingRule struct {
+	Namespace    string   `json:"namespace"`
+	Reason       string   `json:"reason"`
+	Severity     string   `json:"severity"`
+	Backend      string   `json:"backend"`
+	Channel      string   `json:"channel"`
+	Destinations []string `json:"destinations"`
+	Mention      string   `json:"mention"`
+}
+
+// Destination is where route sends an event. Rule is the index of the
+// NOTIFY_RULES entry that matched, -1 when none did and the event goes to
+// every backend, SLACK_CHANNEL or the default webhook pool.
+type Destination struct {
+	Rule         int
+	Backend      string
+	Channel      string
+	Destinations []string
+	Mention      string
+}
+
+// route is the single routing decision of an event: NOTIFY_RULES are
+// evaluated in order and the first rule whose namespace pattern, reason
+// pattern and severity all match the event decides its destination.
+func route(event *v1.Event) Destination {
+	severity := severityOf(event)
+	for i, rule := range cfg.NotifyRules {
+		if rule.Severity != "" && rule.Severity != severity {
+			continue
+		}
+		if ok, _ := path.Match(rule.Namespace, event.InvolvedObject.Namespace); rule.Namespace != "" && !ok {
+			continue
+		}
+		if ok, _ := path.Match(canonicalReason(rule.Reason), canonicalReason(event.Reason)); rule.Reason != "" && !ok {
+			continue
+		}
+		return Destination{Rule: i, Backend: rule.Backend, Channel: rule.Channel, Destinations: rule.Destinations, Mention: rule.Mention}
+	}
+	return Destination{Rule: -1}
+}
+
+// parseRoutingRules parses the JSON list of NOTIFY_RULES, checking every
+// rule against the severities and the backends of NOTIFY_TARGETS. Their
+// destinations are checked by loadRouting, as SLACK_DESTINATIONS can be
+// reloaded.
+func parseRoutingRules(value string, targets []string) ([]routingRule, error) {
+	var rules []routingRule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, err
+	}
+	for i, rule := range rules {
+		if _, err := path.Match(rule.Namespace, ""); err != nil {
+			return nil, fmt.Errorf("rule %d has an invalid namespace pattern %q", i, rule.Namespace)
+		}
+		if _, err := path.Match(rule.Reason, ""); err != nil {
+			return nil, fmt.Errorf("rule %d has an invalid reason pattern %q", i, rule.Reason)
+		}
+		if rule.Channel != "" && len(rule.Destinations) > 0 {
+			return nil, fmt.Errorf("rule %d has both a channel and destinations", i)
+		}
+		if rule.Severity != "" && severityRank(rule.Severity) < 0 {
+			return nil, fmt.Errorf("rule %d has an unknown severity %q, use %s", i, rule.Severity, strings.Join(severities, ", "))
+		}
+		if rule.Backend != "" && !containsString(targets, rule.Backend) {
+			return nil, fmt.Errorf("rule %d routes to %q, which is not in NOTIFY_TARGETS", i, rule.Backend)
+		}
+	}
+	return rules, nil
+}
+
+// routed returns the backends the event is routed to: the backend of the
+// matching rule, or all of them.
+func (m multiNotifier) routed(event *v1.Event) multiNotifier {
+	backend := route(event).Backend
+	if backend == "" {
+		return m
+	}
+	var routed multiNotifier
+	for _, n := range m {
+		if n.Name() == backend {
+			routed = append(routed, n)
+		}
+	}
+	return routed
+}
+
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func normalEvent(namespace, reason string) *v1.Event {
+	event := testEvent(namespace, "Pod", "web-1", reason, "")
+	event.Type = "Normal"
+	return event
+}
+
+func TestRoute(t *testing.T) {
+	rules, err := parseRoutingRules(`[
+		{"namespace": "prod-*", "severity": "critical", "backend": "slack", "channel": "#prod-oncall", "mention": "<!channel>"},
+		{"namespace": "prod-*", "reason": "FailedMount", "channel": "#storage"},
+		{"namespace": "prod-*", "channel": "#prod"},
+		{"namespace": "team-a-*", "destinations": ["team-a", "audit"]},
+		{"severity": "info", "backend": "webhook"},
+		{"namespace": "staging", "mention": "<@oncall>"}
+	]`, []string{"slack", "webhook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, &Config{NotifyRules: rules, CriticalReasons: []string{"OOMKilling"}})
+
+	for _, test := range []struct {
+		name  string
+		event *v1.Event
+		want  Destination
+	}{
+		{"first match wins over later matching rules",
+			testEvent("prod-api", "Pod", "api-1", "OOMKilling", ""),
+			Destination{Rule: 0, Backend: "slack", Channel: "#prod-oncall", Mention: "<!channel>"}},
+		{"reason pattern",
+			testEvent("prod-api", "Pod", "api-1", "FailedMount", ""),
+			Destination{Rule: 1, Channel: "#storage"}},
+		{"severity mismatch falls through",
+			testEvent("prod-api", "Pod", "api-1", "BackOff", ""),
+			Destination{Rule: 2, Channel: "#prod"}},
+		{"earlier namespace rule wins over a later severity rule",
+			normalEvent("prod-api", "Pulled"),
+			Destination{Rule: 2, Channel: "#prod"}},
+		{"destinations",
+			testEvent("team-a-web", "Pod", "web-1", "BackOff", ""),
+			Destination{Rule: 3, Destinations: []string{"team-a", "audit"}}},
+		{"omitted namespace matches any namespace",
+			normalEvent("staging", "Pulled"),
+			Destination{Rule: 4, Backend: "webhook"}},
+		{"mention only",
+			testEvent("staging", "Pod", "web-1", "BackOff", ""),
+			Destination{Rule: 5, Mention: "<@oncall>"}},
+		{"no match",
+			testEvent("dev", "Pod", "web-1", "BackOff", ""),
+			Destination{Rule: -1}},
+	} {
+		if got := route(test.event); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: routed to %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRouteWithoutRules(t *testing.T) {
+	withConfig(t, &Config{})
+	if got := route(testEvent("prod", "Pod", "web-1", "BackOff", "")); !reflect.DeepEqual(got, Destination{Rule: -1}) {
+		t.Errorf("routed to %+v without rules", got)
+	}
+}
+
+func TestRouteNormalizedReason(t *testing.T) {
+	rules, err := parseRoutingRules(`[{"reason": "Failed*", "channel": "#failures"}]`, []string{"slack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, &Config{NotifyRules: rules, NormalizeReasons: true})
+	if got := route(testEvent("prod", "Pod", "web-1", "failedMount", "")); got.Rule != 0 {
+		t.Errorf("reason differing in case was not matched: %+v", got)
+	}
+}
+
+func TestParseRoutingRules(t *testing.T) {
+	for _, test := range []struct {
+		rules string
+		err   string
+	}{
+		{`[{"namespace": "prod-["}]`, "invalid namespace pattern"},
+		{`[{"reason": "Failed["}]`, "invalid reason pattern"},
+		{`[{"severity": "fatal"}]`, "unknown severity"},
+		{`[{"backend": "email"}]`, "not in NOTIFY_TARGETS"},
+		{`[{"channel": "#a", "destinations": ["a"]}]`, "both a channel and destinations"},
+		{`{"channel": "#a"}`, "cannot unmarshal"},
+	} {
+		if _, err := parseRoutingRules(test.rules, []string{"slack"}); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.rules, err, test.err)
+		}
+	}
+}
+
+func TestRuleDestinationsMustExist(t *testing.T) {
+	rules, err := parseRoutingRules(`[{"destinations": ["team-b"]}]`, []string{"slack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, &Config{NotifyRules: rules})
+	r := &slackRouting{destinations: map[string]slackDestination{"team-a": {Webhook: "https://hooks.slack.com/services/T/B/x"}}}
+	if err := r.validate(); err == nil || !strings.Contains(err.Error(), `unknown destination "team-b"`) {
+		t.Errorf("got %v, want an unknown destination error", err)
+	}
+}
+
+func TestNotifySlackWithoutDestination(t *testing.T) {
+	rules, err := parseRoutingRules(`[{"mention": "<@oncall>"}]`, []string{"slack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, &Config{NotifyRules: rules, SlackFormat: "legacy"})
+	saved := webhooks
+	webhooks = nil
+	t.Cleanup(func() { webhooks = saved })
+
+	if err := notifySlack(testEvent("prod", "Pod", "web-1", "BackOff", "Back-off restarting failed container"), &enrichment{}); err == nil {
+		t.Error("an event with no Slack destination was dropped without an error")
+	}
+}
+
+func TestMentionOnlyRuleKeepsDefaultDestination(t *testing.T) {
+	rules, err := parseRoutingRules(`[{"mention": "<@oncall>"}]`, []string{"slack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, &Config{NotifyRules: rules, SlackFormat: "legacy"})
+	withSlackWebhook(t, http.StatusOK)
+
+	if err := notifySlack(testEvent("prod", "Pod", "web-1", "BackOff", "Back-off restarting failed container"), &enrichment{}); err != nil {
+		t.Errorf("a rule setting only a mention did not post to the default webhook: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// selfTestPassed is set once the startup self-test delivered its
+// notification; readiness fails until then.
+var selfTestPassed int32
+
+// runSelfTest runs the self-test on startup and retries every minute until
+// it passes, e.g. after the webhooks were fixed and reloaded with SIGHUP.
+func runSelfTest() {
+	for {
+		err := selfTest()
+		if err == nil {
+			log.Println("Startup self-test passed")
+			return
+		}
+		log.Printf("Startup self-test failed, retrying in a minute: %v", err)
+		time.Sleep(time.Minute)
+	}
+}
+
+// selfTest notifies a synthetic event through every backend, routed as
+// events of SELFTEST_NAMESPACE so that it can be sent to a test channel,
+// and marks the self-test passed once every backend accepted it.
+func selfTest() error {
+	now := unversioned.Now()
+	event := &v1.Event{
+		InvolvedObject: v1.ObjectReference{Namespace: cfg.SelfTestNamespace},
+		Type:           "Normal",
+		Reason:         "SelfTest",
+		Message:        "Notifications are delivered, startup self-test passed",
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if err := notifier.Notify(event, &enrichment{}); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&selfTestPassed, 1)
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSelfTestKeepsReadinessFailing(t *testing.T) {
+	withConfig(t, &Config{SlackFormat: "legacy", StartupSelfTest: true})
+	saved := notifier
+	notifier = multiNotifier{slackNotifier{}}
+	t.Cleanup(func() {
+		notifier = saved
+		atomic.StoreInt32(&selfTestPassed, 0)
+	})
+
+	withSlackWebhook(t, http.StatusForbidden)
+	if err := selfTest(); err == nil {
+		t.Fatal("the self-test passed with a revoked webhook")
+	}
+	if readiness() == nil {
+		t.Error("ready after a failed self-test")
+	}
+
+	withSlackWebhook(t, http.StatusOK)
+	if err := selfTest(); err != nil {
+		t.Fatalf("the self-test failed: %v", err)
+	}
+	if err := readiness(); err != nil {
+		t.Errorf("not ready after the self-test passed: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sendHealth notices when notifications keep failing for FAILURE_ALERT_AFTER
+// without a single success, meaning nobody is told about anything anymore.
+// As notifying is what is broken, it is surfaced out of band instead: an
+// error is logged, the readiness endpoint fails and FAILURE_MARKER_FILE is
+// created, until a notification goes through again.
+type sendHealth struct {
+	after      time.Duration
+	markerFile string
+
+	mu           sync.Mutex
+	failingSince time.Time // zero while the last attempt succeeded
+	broken       bool
+}
+
+var sends *sendHealth
+
+func newSendHealth(after time.Duration, markerFile string) *sendHealth {
+	return &sendHealth{after: after, markerFile: markerFile}
+}
+
+func (h *sendHealth) succeeded() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.failingSince = time.Time{}
+	if !h.broken {
+		return
+	}
+	h.broken = false
+	slog.Info("Notifications are being delivered again")
+	if h.markerFile != "" {
+		if err := os.Remove(h.markerFile); err != nil && !os.IsNotExist(err) {
+			slog.Error("Unable to remove the failure marker file", "path", h.markerFile, "err", err)
+		}
+	}
+}
+
+func (h *sendHealth) failed() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if h.failingSince.IsZero() {
+		h.failingSince = now
+	}
+	h.check(now)
+}
+
+// check flags the pipeline as broken once it has failed for long enough.
+// The caller holds h.mu.
+func (h *sendHealth) check(now time.Time) {
+	if h.broken || h.failingSince.IsZero() || now.Sub(h.failingSince) < h.after {
+		return
+	}
+	h.broken = true
+	slog.Error("No notification could be delivered for FAILURE_ALERT_AFTER, events are going unreported", "since", h.failingSince.Format(time.RFC3339))
+	if h.markerFile != "" {
+		if err := ioutil.WriteFile(h.markerFile, []byte(h.failingSince.Format(time.RFC3339)+"\n"), 0644); err != nil {
+			slog.Error("Unable to write the failure marker file", "path", h.markerFile, "err", err)
+		}
+	}
+}
+
+// run checks periodically, as the threshold may pass between attempts.
+func (h *sendHealth) run() {
+	for range time.Tick(time.Minute) {
+		h.mu.Lock()
+		h.check(time.Now())
+		h.mu.Unlock()
+	}
+}
+
+func (h *sendHealth) healthy() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.broken
+}
+
+// recordSend tracks the outcome of a notification attempt when
+// FAILURE_ALERT_AFTER is set.
+func recordSend(err error) {
+	if sends == nil {
+		return
+	}
+	if err != nil {
+		sends.failed()
+	} else {
+		sends.succeeded()
+	}
+}
+
+// readiness returns why notifications can't be delivered, or nil.
+func readiness() error {
+	if cfg.StartupSelfTest && atomic.LoadInt32(&selfTestPassed) == 0 {
+		return errors.New("the startup self-test has not passed")
+	}
+	if sends != nil && !sends.healthy() {
+		return errors.New("notifications are failing")
+	}
+	return nil
+}
+
+// readyHandler fails while notifications can't be delivered.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if err := readiness(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/types"
+)
+
+// seriesTTL is how long an event series is remembered after its last
+// occurrence, the API server's default event TTL.
+const seriesTTL = time.Hour
+
+// seriesTracker recognizes the updates Kubernetes makes to an event it
+// aggregates, the same event UID with a higher count, and handles them
+// according to SERIES_MODE instead of as new notifications:
+//
+//	suppress  drops them
+//	update    updates the Slack message of the series with the new count
+//	thread    replies in the thread of that message
+//
+// Updating and threading need the bot token, as webhooks return no message
+// to refer to; without it updates are suppressed.
+type seriesTracker struct {
+	mode string
+
+	mu        sync.Mutex
+	series    map[types.UID]*eventSeries
+	nextSweep time.Time
+}
+
+type eventSeries struct {
+	count    int32
+	lastSeen time.Time
+
+	// The Slack message posted for the series, in bot token mode.
+	token     string
+	channel   string
+	channelID string
+	ts        string
+}
+
+var series *seriesTracker
+
+func newSeriesTracker(mode string) *seriesTracker {
+	return &seriesTracker{mode: mode, series: map[types.UID]*eventSeries{}}
+}
+
+// entry returns the series of the event, creating it. The caller holds
+// t.mu.
+func (t *seriesTracker) entry(event *v1.Event) *eventSeries {
+	now := time.Now()
+	if now.After(t.nextSweep) {
+		for uid, s := range t.series {
+			if now.Sub(s.lastSeen) > seriesTTL {
+				delete(t.series, uid)
+			}
+		}
+		t.nextSweep = now.Add(time.Minute)
+	}
+	s, ok := t.series[event.UID]
+	if !ok {
+		s = &eventSeries{}
+		t.series[event.UID] = s
+	}
+	s.lastSeen = now
+	return s
+}
+
+// track records that the event was notified.
+func (t *seriesTracker) track(event *v1.Event) {
+	if event.UID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entry(event).count = event.Count
+}
+
+// posted records the Slack message the event was first posted as, with the
+// destination's token, empty for SLACK_BOT_TOKEN.
+func (t *seriesTracker) posted(event *v1.Event, token, channel string, message *slackAPIResponse) {
+	if event.UID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.entry(event)
+	if s.ts == "" {
+		s.token, s.channel, s.channelID, s.ts = token, channel, message.Channel, message.TS
+	}
+}
+
+// wouldFollow reports whether follow would handle the event, without
+// handling it.
+func (t *seriesTracker) wouldFollow(event *v1.Event) bool {
+	if event.UID == "" {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.series[event.UID]
+	return ok && s.count > 0
+}
+
+// follow handles the event if it continues a series already notified,
+// reporting whether it did.
+func (t *seriesTracker) follow(clientset *kubernetes.Clientset, event *v1.Event) bool {
+	if event.UID == "" {
+		return false
+	}
+	t.mu.Lock()
+	s, ok := t.series[event.UID]
+	if !ok || s.count == 0 {
+		t.mu.Unlock()
+		return false
+	}
+	previous := s.count
+	if event.Count > s.count {
+		s.count = event.Count
+	}
+	s.lastSeen = time.Now()
+	token, channel, channelID, ts := s.token, s.channel, s.channelID, s.ts
+	t.mu.Unlock()
+
+	if event.Count <= previous {
+		// The same occurrence again, e.g. after the watch was restarted.
+		return true
+	}
+	switch {
+	case t.mode == "suppress" || ts == "":
+		slog.Debug("Suppressed series update", "uid", event.UID, "count", event.Count)
+	case t.mode == "update":
+		message, err := formatSlackMessage(event, enrichEvent(clientset, event))
+		if err == nil {
+			err = updateSlackAPI(apiToken(token), channelID, ts, message)
+		}
+		if err != nil {
+			log.Printf("Unable to update the message of %s on %s/%s: %v", event.Reason, event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+		}
+	case t.mode == "thread":
+		reply := SlackMessage{
+			ThreadTS: ts,
+			Text:     fmt.Sprintf("Seen %d times, last at %s", event.Count, event.LastTimestamp.Format(time.RFC3339)),
+		}
+		if _, err := postSlackAPI(apiToken(token), channel, reply); err != nil {
+			log.Printf("Unable to reply to the message of %s on %s/%s: %v", event.Reason, event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func seriesEvent(count int32) *v1.Event {
+	event := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+	event.UID = "uid-1"
+	event.Count = count
+	return event
+}
+
+func TestSeriesSuppressesIncreasingCount(t *testing.T) {
+	withConfig(t, &Config{})
+	tracker := newSeriesTracker("suppress")
+
+	if tracker.follow(nil, seriesEvent(1)) {
+		t.Fatal("the first event of a series was handled as an update")
+	}
+	tracker.track(seriesEvent(1))
+	for _, count := range []int32{2, 5, 5, 3} {
+		if !tracker.follow(nil, seriesEvent(count)) {
+			t.Errorf("count %d of a notified series was not handled as an update", count)
+		}
+	}
+	other := seriesEvent(6)
+	other.UID = "uid-2"
+	if tracker.follow(nil, other) {
+		t.Error("an event of another series was handled as an update")
+	}
+}
+
+func TestSeriesThreadsIncreasingCount(t *testing.T) {
+	withConfig(t, &Config{})
+	var replies []SlackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message SlackMessage
+		json.NewDecoder(r.Body).Decode(&message)
+		replies = append(replies, message)
+		json.NewEncoder(w).Encode(slackAPIResponse{OK: true, Channel: "C1", TS: "2.0"})
+	}))
+	defer server.Close()
+	saved := slackAPI
+	slackAPI = server.URL + "/"
+	t.Cleanup(func() { slackAPI = saved })
+
+	tracker := newSeriesTracker("thread")
+	tracker.track(seriesEvent(1))
+	tracker.posted(seriesEvent(1), "xoxb-1", "#alerts", &slackAPIResponse{Channel: "C1", TS: "1.0"})
+
+	tracker.follow(nil, seriesEvent(3))
+	tracker.follow(nil, seriesEvent(3))
+	tracker.follow(nil, seriesEvent(4))
+	if len(replies) != 2 {
+		t.Fatalf("%d replies, want one per increase of the count", len(replies))
+	}
+	for _, reply := range replies {
+		if reply.ThreadTS != "1.0" || reply.Channel != "#alerts" {
+			t.Errorf("reply %+v not in the thread of the series", reply)
+		}
+	}
+}
+
+func TestSimulateFollowsSeries(t *testing.T) {
+	withConfig(t, &Config{})
+	sink := &fakeSink{name: "slack"}
+	withNotifier(t, multiNotifier{sink})
+	savedDedup, savedSeries := dedup, series
+	dedup, series = newDedupCache(time.Hour, 0, nil), newSeriesTracker("suppress")
+	t.Cleanup(func() { dedup, series = savedDedup, savedSeries })
+
+	handleEvent(nil, seriesEvent(1))
+	// Once deduplication forgot the event, only the series tells its
+	// updates apart.
+	dedup = newDedupCache(time.Hour, 0, nil)
+	if got := simulate(seriesEvent(2)).Decision; got != "series" {
+		t.Errorf("simulated decision %q, want series", got)
+	}
+	handleEvent(nil, seriesEvent(2))
+	if sink.notified != 1 {
+		t.Errorf("notified %d times, want the series update suppressed as simulated", sink.notified)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// severities lists the levels events are classified in, lowest first.
+var severities = []string{"info", "warning", "critical"}
+
+func severityRank(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// severityOf classifies the event: Normal events are info, Warning events
+// are critical when their reason is one of CRITICAL_REASONS and warning
+// otherwise.
+func severityOf(event *v1.Event) string {
+	if event.Type == "Normal" {
+		return "info"
+	}
+	for _, reason := range cfg.CriticalReasons {
+		if canonicalReason(reason) == canonicalReason(event.Reason) {
+			return "critical"
+		}
+	}
+	return "warning"
+}
+
+// businessHours is a weekly window such as "Mon-Fri 09:00-18:00" in a
+// given time zone.
+type businessHours struct {
+	days       [7]bool
+	start, end int // minutes since midnight
+	location   *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseBusinessHours parses "[days ]HH:MM-HH:MM", where days is a comma
+// separated list of days or day ranges, e.g. "Mon-Fri" or "Mon,Wed-Thu".
+// Every day is included when days is omitted.
+func parseBusinessHours(spec string, location *time.Location) (*businessHours, error) {
+	b := &businessHours{location: location}
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 1:
+		for i := range b.days {
+			b.days[i] = true
+		}
+	case 2:
+		for _, part := range strings.Split(fields[0], ",") {
+			bounds := strings.SplitN(part, "-", 2)
+			first, ok := weekdays[strings.ToLower(bounds[0])]
+			last := first
+			if len(bounds) == 2 {
+				var lastOK bool
+				last, lastOK = weekdays[strings.ToLower(bounds[1])]
+				ok = ok && lastOK
+			}
+			if !ok {
+				return nil, fmt.Errorf("invalid days %q", part)
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				b.days[d] = true
+				if d == last {
+					break
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("expected \"[days ]HH:MM-HH:MM\"")
+	}
+
+	times := strings.SplitN(fields[len(fields)-1], "-", 2)
+	if len(times) != 2 {
+		return nil, fmt.Errorf("invalid hours %q", fields[len(fields)-1])
+	}
+	var err error
+	if b.start, err = parseClock(times[0]); err != nil {
+		return nil, err
+	}
+	if b.end, err = parseClock(times[1]); err != nil {
+		return nil, err
+	}
+	if b.end <= b.start {
+		return nil, fmt.Errorf("hours %q end before they start", fields[len(fields)-1])
+	}
+	return b, nil
+}
+
+// parseClock converts "HH:MM" to minutes since midnight.
+func parseClock(clock string) (int, error) {
+	parts := strings.SplitN(clock, ":", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q", clock)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, fmt.Errorf("invalid time %q", clock)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("invalid time %q", clock)
+	}
+	return hours*60 + minutes, nil
+}
+
+func (b *businessHours) contains(t time.Time) bool {
+	t = t.In(b.location)
+	minute := t.Hour()*60 + t.Minute()
+	return b.days[t.Weekday()] && minute >= b.start && minute < b.end
+}
+
+// offHours reports whether the event falls outside BUSINESS_HOURS with a
+// severity below OFF_HOURS_MIN_SEVERITY.
+func offHours(event *v1.Event, now time.Time) bool {
+	if cfg.BusinessHours == nil || cfg.BusinessHours.contains(now) {
+		return false
+	}
+	return severityRank(severityOf(event)) < severityRank(cfg.OffHoursMinSeverity)
+}
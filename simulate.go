@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// simulation is the outcome of running an event through the pipeline
+// without notifying or recording anything.
+type simulation struct {
+	Decision string                 `json:"decision"`
+	DedupKey string                 `json:"dedupKey"`
+	Severity string                 `json:"severity"`
+	Payloads map[string]interface{} `json:"payloads,omitempty"`
+}
+
+// simulateHandler accepts a JSON v1.Event on POST and reports how it would
+// be handled: the decision taken, and for events that would be notified
+// the payload rendered for every backend.
+func simulateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST a v1.Event", http.StatusMethodNotAllowed)
+		return
+	}
+	event := &v1.Event{}
+	if err := json.NewDecoder(r.Body).Decode(event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(simulate(event))
+}
+
+// simulate runs the event through the checks of watchEvents and the
+// decision chain of handleEvent as a dry run, which changes no state.
+func simulate(event *v1.Event) simulation {
+	clientset := currentClientset()
+	if cfg.NormalizeReasons {
+		event.Reason = strings.TrimSpace(event.Reason)
+	}
+	result := simulation{Severity: severityOf(event)}
+	switch {
+	case selfEvent(event):
+		result.Decision = "self-event"
+		result.DedupKey = dedupKey(event)
+	case !shouldNotifyTypeReason(event.Type, event.Reason):
+		result.Decision = "filtered"
+		result.DedupKey = dedupKey(event)
+	default:
+		d := decide(clientset, event, true)
+		result.Decision, result.DedupKey = d.action, d.key
+		if d.action == "notify" {
+			extra := enrichEvent(clientset, event)
+			if cfg.ShowCountDelta {
+				extra.PreviousCount = dedup.lastCount(d.key)
+			}
+			result.Payloads = renderPayloads(d.targets, event, extra)
+		}
+	}
+	return result
+}
+
+// renderPayloads renders what every backend would send.
+func renderPayloads(backends multiNotifier, event *v1.Event, extra *enrichment) map[string]interface{} {
+	payloads := map[string]interface{}{}
+	for _, n := range backends {
+		switch n.Name() {
+		case "slack":
+			message, err := formatSlackMessage(event, extra)
+			if err != nil {
+				payloads[n.Name()] = err.Error()
+			} else {
+				payloads[n.Name()] = message
+			}
+		case "stdout":
+			payloads[n.Name()] = buildSlackMessage(n.Name(), event, extra)
+		case "webhook":
+			payloads[n.Name()] = remapPayload(newWebhookPayload(n.Name(), event, extra))
+		case "unix":
+			payloads[n.Name()] = newWebhookPayload(n.Name(), event, extra)
+		case "googlechat":
+			payloads[n.Name()] = buildGoogleChatMessage(event, extra)
+		}
+	}
+	return payloads
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// slackAPI is the base URL of the Slack Web API, which is posted to instead
+// of webhooks when SLACK_BOT_TOKEN or a destination token is set.
+var slackAPI = "https://slack.com/api/"
+
+var (
+	botTokenMu sync.RWMutex
+	botToken   string
+)
+
+// currentBotToken is SLACK_BOT_TOKEN, as last (re)loaded.
+func currentBotToken() string {
+	botTokenMu.RLock()
+	defer botTokenMu.RUnlock()
+	return botToken
+}
+
+// apiToken is the token to post with for a destination token, which is
+// SLACK_BOT_TOKEN when empty. Posts remembered to be updated later keep the
+// destination token, so they are updated with a rotated bot token.
+func apiToken(token string) string {
+	if token == "" {
+		return currentBotToken()
+	}
+	return token
+}
+
+// reloadBotToken reads SLACK_BOT_TOKEN again, picking up a rotated
+// SLACK_BOT_TOKEN_FILE. Setting or removing the token takes a restart, as
+// it switches between webhooks and the Web API.
+func reloadBotToken() error {
+	token, err := secretEnv("SLACK_BOT_TOKEN")
+	if err != nil {
+		return err
+	}
+	botTokenMu.Lock()
+	defer botTokenMu.Unlock()
+	if (token == "") != (botToken == "") {
+		return fmt.Errorf("SLACK_BOT_TOKEN can only be set or removed by a restart")
+	}
+	botToken = token
+	return nil
+}
+
+// slackAPIResponse holds the fields of Web API responses used here.
+type slackAPIResponse struct {
+	OK        bool   `json:"ok"`
+	Error     string `json:"error"`
+	Channel   string `json:"channel"`
+	TS        string `json:"ts"`
+	Permalink string `json:"permalink"`
+}
+
+// postSlackAPI sends the message to the channel with chat.postMessage,
+// authenticated with the bot token of the channel's workspace and refusing
+// channels left out of ALLOWED_CHANNELS.
+func postSlackAPI(token, channel string, message SlackMessage) (*slackAPIResponse, error) {
+	if !channelAllowed(channel) {
+		slog.Error("Refused to post to a channel not in ALLOWED_CHANNELS", "channel", channel)
+		return nil, fmt.Errorf("channel %q is not allowed", channel)
+	}
+	message.Channel = channel
+	return sendSlackAPI(token, "chat.postMessage", message)
+}
+
+// updateSlackAPI replaces a posted message with chat.update, which takes
+// the channel ID returned when it was posted rather than its name.
+func updateSlackAPI(token, channelID, ts string, message SlackMessage) error {
+	message.Channel, message.TS = channelID, ts
+	_, err := sendSlackAPI(token, "chat.update", message)
+	return err
+}
+
+func sendSlackAPI(token, method string, message SlackMessage) (*slackAPIResponse, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", slackAPI+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	return callSlackAPI(token, req)
+}
+
+// channelAllowed reports whether ALLOWED_CHANNELS, when set, lists the
+// channel. A leading "#" is ignored on both sides.
+func channelAllowed(channel string) bool {
+	if len(cfg.AllowedChannels) == 0 {
+		return true
+	}
+	for _, allowed := range cfg.AllowedChannels {
+		if strings.TrimPrefix(allowed, "#") == strings.TrimPrefix(channel, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// slackPermalink looks up the permalink of a posted message.
+func slackPermalink(token, channel, ts string) (string, error) {
+	query := url.Values{"channel": {channel}, "message_ts": {ts}}
+	req, err := http.NewRequest("GET", slackAPI+"chat.getPermalink?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := callSlackAPI(token, req)
+	if err != nil {
+		return "", err
+	}
+	return resp.Permalink, nil
+}
+
+// callSlackAPI authenticates the request with the bot token and decodes the
+// response, which Slack reports errors in with a 200 status.
+func callSlackAPI(token string, req *http.Request) (*slackAPIResponse, error) {
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s responded %s", req.URL.Path, resp.Status)
+	}
+	result := &slackAPIResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, err
+	}
+	if !result.OK {
+		return nil, fmt.Errorf("%s failed: %s", req.URL.Path, result.Error)
+	}
+	return result, nil
+}
+
+// logPermalink logs where the notification about the event was posted, so
+// an alert can be found in the channel from the logs.
+func logPermalink(token string, posted *slackAPIResponse, event *v1.Event) {
+	permalink, err := slackPermalink(token, posted.Channel, posted.TS)
+	if err != nil {
+		log.Printf("Unable to fetch the permalink of %s on %s/%s: %v", event.Reason, event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+		return
+	}
+	log.Printf("Posted %s on %s/%s: %s", event.Reason, event.InvolvedObject.Namespace, event.InvolvedObject.Name, permalink)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// socketNotifier writes newline delimited JSON events to a Unix domain
+// socket for a co-located agent to forward. The connection is reopened on
+// the next notification after a write fails.
+type socketNotifier struct {
+	path string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (*socketNotifier) Name() string { return "unix" }
+
+func (n *socketNotifier) Notify(event *v1.Event, extra *enrichment) error {
+	line, err := json.Marshal(newWebhookPayload("unix", event, extra))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		if n.conn, err = net.DialTimeout("unix", n.path, 5*time.Second); err != nil {
+			n.conn = nil
+			return err
+		}
+	}
+	n.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := n.conn.Write(line); err != nil {
+		n.conn.Close()
+		n.conn = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// stormTracker lets the first STORM_DETAIL_LIMIT events of a cause through
+// in detail, then only posts a periodic summary of that cause until it has
+// been quiet for STORM_QUIET_PERIOD, when a final note is posted. A cause
+// is a reason within a namespace of a cluster.
+type stormTracker struct {
+	limit    int
+	interval time.Duration
+	quiet    time.Duration
+
+	mu     sync.Mutex
+	storms map[string]*storm
+}
+
+type storm struct {
+	cluster   string
+	namespace string
+	reason    string
+	detailed  int
+	total     int
+	pending   int
+	lastSeen  time.Time
+}
+
+func newStormTracker(limit int, interval, quiet time.Duration) *stormTracker {
+	return &stormTracker{limit: limit, interval: interval, quiet: quiet, storms: map[string]*storm{}}
+}
+
+// stormKey is the cause of the event.
+func stormKey(event *v1.Event) string {
+	return event.ClusterName + "/" + event.InvolvedObject.Namespace + "/" + canonicalReason(event.Reason)
+}
+
+// admit counts the event towards its storm and reports whether it should
+// still be notified in detail.
+func (t *stormTracker) admit(event *v1.Event) bool {
+	key := stormKey(event)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.storms[key]
+	if !ok {
+		s = &storm{cluster: event.ClusterName, namespace: event.InvolvedObject.Namespace, reason: event.Reason}
+		t.storms[key] = s
+	}
+	s.total++
+	s.lastSeen = time.Now()
+	if s.detailed < t.limit {
+		s.detailed++
+		return true
+	}
+	s.pending++
+	return false
+}
+
+// wouldAdmit reports what admit would without counting the event.
+func (t *stormTracker) wouldAdmit(event *v1.Event) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.storms[stormKey(event)]
+	return !ok || s.detailed < t.limit
+}
+
+func (t *stormTracker) run() {
+	for range time.Tick(t.interval) {
+		for _, event := range t.summaries() {
+			if err := notifier.Notify(event, &enrichment{}); err != nil {
+				log.Printf("Unable to post the %s storm summary for %s: %v", event.Reason, event.InvolvedObject.Namespace, err)
+			}
+		}
+	}
+}
+
+// summaries returns the notifications due this interval: a summary for
+// each storm with events held back, and a closing note for each storm that
+// went quiet after exceeding the limit.
+func (t *stormTracker) summaries() []*v1.Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var events []*v1.Event
+	for key, s := range t.storms {
+		switch {
+		case s.pending > 0:
+			events = append(events, s.event("Warning", fmt.Sprintf("Still failing: %d more events in the last %v", s.pending, t.interval)))
+			s.pending = 0
+		case time.Since(s.lastSeen) > t.quiet:
+			if s.total > t.limit {
+				events = append(events, s.event("Normal", fmt.Sprintf("Subsided after %d events", s.total)))
+			}
+			delete(t.storms, key)
+		}
+	}
+	return events
+}
+
+func (s *storm) event(eventType, message string) *v1.Event {
+	now := unversioned.Now()
+	event := &v1.Event{
+		InvolvedObject: v1.ObjectReference{Namespace: s.namespace},
+		Type:           eventType,
+		Reason:         s.reason,
+		Message:        message,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          int32(s.total),
+	}
+	event.ClusterName = s.cluster
+	return event
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStormsArePerCluster(t *testing.T) {
+	storms := newStormTracker(1, time.Minute, time.Minute)
+	first := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+	first.ClusterName = "east"
+	second := testEvent("app", "Pod", "web-2", "BackOff", "Back-off restarting failed container")
+	second.ClusterName = "west"
+
+	if !storms.admit(first) {
+		t.Fatal("the first event of a storm was held back")
+	}
+	if !storms.admit(second) {
+		t.Error("an event of another cluster was held back by the storm of the first")
+	}
+	third := testEvent("app", "Pod", "web-3", "BackOff", "Back-off restarting failed container")
+	third.ClusterName = "east"
+	if storms.admit(third) {
+		t.Error("an event past the limit of its cluster's storm was admitted")
+	}
+	for _, event := range storms.summaries() {
+		if event.ClusterName != "east" {
+			t.Errorf("summary of the %q storm, want only the east one", event.ClusterName)
+		}
+	}
+}
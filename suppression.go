@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+var eventsSuppressed = newCounterVec("events_suppressed_total", "Events held back instead of notified, by why they were.", "reason")
+
+// suppressionLog records suppressions when SUPPRESSION_LOG is on, whatever
+// LOG_LEVEL is.
+var suppressionLog = slog.New(slog.NewTextHandler(os.Stderr, nil)).With("category", "suppression")
+
+// suppress counts an event held back for the given reason.
+func suppress(event *v1.Event, reason string) {
+	eventsSuppressed.inc(reason)
+	if cfg.SuppressionLog == "on" {
+		suppressionLog.Info("Suppressed", "why", reason, "namespace", event.InvolvedObject.Namespace, "kind", event.InvolvedObject.Kind, "name", event.InvolvedObject.Name, "reason", event.Reason)
+	}
+	recordDecision(event, "suppressed", reason, dedupKey(event))
+	if suppressions != nil {
+		suppressions.add(event)
+	}
+}
+
+// suppressionMonitor watches the suppression rate and sends one summary
+// when it stays above SUPPRESSION_ALERT_RATE events a minute for
+// SUPPRESSION_ALERT_AFTER, as a storm muted by deduplication and rate
+// limiting is itself worth knowing about. It alerts again only after the
+// rate has dropped below the threshold.
+type suppressionMonitor struct {
+	rate  int
+	after time.Duration
+
+	mu         sync.Mutex
+	count      int
+	namespaces map[string]bool
+
+	highFor time.Duration
+	alerted bool
+}
+
+var suppressions *suppressionMonitor
+
+func newSuppressionMonitor(rate int, after time.Duration) *suppressionMonitor {
+	return &suppressionMonitor{rate: rate, after: after, namespaces: map[string]bool{}}
+}
+
+func (m *suppressionMonitor) add(event *v1.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.count++
+	m.namespaces[event.InvolvedObject.Namespace] = true
+}
+
+func (m *suppressionMonitor) run() {
+	for range time.Tick(time.Minute) {
+		if event := m.check(); event != nil {
+			if err := notifier.Notify(event, &enrichment{}); err != nil {
+				log.Printf("Unable to notify the high suppression rate: %v", err)
+			}
+		}
+	}
+}
+
+// check closes the current minute, returning the summary to send if the
+// rate has just been high for long enough.
+func (m *suppressionMonitor) check() *v1.Event {
+	m.mu.Lock()
+	count, namespaces := m.count, len(m.namespaces)
+	m.count, m.namespaces = 0, map[string]bool{}
+	m.mu.Unlock()
+
+	if count < m.rate {
+		m.highFor, m.alerted = 0, false
+		return nil
+	}
+	m.highFor += time.Minute
+	if m.alerted || m.highFor < m.after {
+		return nil
+	}
+	m.alerted = true
+	now := unversioned.Now()
+	return &v1.Event{
+		Type:           "Warning",
+		Reason:         "HighSuppression",
+		Message:        fmt.Sprintf("High suppression: %d events/min suppressed across %d namespaces for %v", count, namespaces, m.highFor),
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          int32(count),
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// templateData is what message templates are executed with.
+type templateData struct {
+	Event      *v1.Event
+	Namespace  string
+	Kind       string
+	Name       string
+	Reason     string
+	Message    string
+	Count      int32
+	Controller string
+}
+
+var backendNames = []string{"slack", "webhook", "unix", "googlechat", "stdout"}
+
+var (
+	// backendTemplates holds the message template of each backend that has
+	// one, read from <BACKEND>_TEMPLATE, e.g. SLACK_TEMPLATE.
+	backendTemplates = map[string]*template.Template{}
+
+	// reasonTemplates holds the templates tailored to a reason, read from
+	// the REASON_TEMPLATES JSON object and keyed by canonical reason.
+	reasonTemplates = map[string]*template.Template{}
+
+	// broadcastTemplate renders the thread replies broadcast to the
+	// channel, read from SLACK_BROADCAST_TEMPLATE.
+	broadcastTemplate *template.Template
+)
+
+func loadTemplates() error {
+	backends := map[string]*template.Template{}
+	for _, backend := range backendNames {
+		name := strings.ToUpper(backend) + "_TEMPLATE"
+		text := os.Getenv(name)
+		if text == "" {
+			continue
+		}
+		t, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+		backends[backend] = t
+	}
+
+	reasons := map[string]*template.Template{}
+	if value := os.Getenv("REASON_TEMPLATES"); value != "" {
+		var texts map[string]string
+		if err := json.Unmarshal([]byte(value), &texts); err != nil {
+			return fmt.Errorf("invalid REASON_TEMPLATES: %v", err)
+		}
+		for reason, text := range texts {
+			t, err := template.New("REASON_TEMPLATES[" + reason + "]").Option("missingkey=error").Parse(text)
+			if err != nil {
+				return fmt.Errorf("invalid REASON_TEMPLATES template for %s: %v", reason, err)
+			}
+			reasons[canonicalReason(reason)] = t
+		}
+	}
+
+	var broadcast *template.Template
+	if text := os.Getenv("SLACK_BROADCAST_TEMPLATE"); text != "" {
+		var err error
+		if broadcast, err = template.New("SLACK_BROADCAST_TEMPLATE").Option("missingkey=error").Parse(text); err != nil {
+			return fmt.Errorf("invalid SLACK_BROADCAST_TEMPLATE: %v", err)
+		}
+	}
+
+	backendTemplates, reasonTemplates, broadcastTemplate = backends, reasons, broadcast
+	return nil
+}
+
+// renderMessage is the text a backend shows for an event: the template for
+// its reason if there is one, else the backend's own template, else the
+// event message.
+func renderMessage(backend string, event *v1.Event, extra *enrichment) string {
+	t, ok := reasonTemplates[canonicalReason(event.Reason)]
+	if !ok {
+		t, ok = backendTemplates[backend]
+	}
+	if !ok {
+		return eventMessage(event)
+	}
+	text, err := executeTemplate(t, event, extra)
+	if err != nil {
+		log.Printf("Unable to render %s: %v", t.Name(), err)
+		return eventMessage(event)
+	}
+	return text
+}
+
+// eventMessage is the event's message, or EMPTY_MESSAGE_PLACEHOLDER when it
+// is blank.
+func eventMessage(event *v1.Event) string {
+	if strings.TrimSpace(event.Message) == "" {
+		return cfg.EmptyMessage
+	}
+	return event.Message
+}
+
+func executeTemplate(t *template.Template, event *v1.Event, extra *enrichment) (string, error) {
+	data := templateData{
+		Event:     event,
+		Namespace: event.InvolvedObject.Namespace,
+		Kind:      event.InvolvedObject.Kind,
+		Name:      event.InvolvedObject.Name,
+		Reason:    event.Reason,
+		Message:   eventMessage(event),
+		Count:     event.Count,
+	}
+	if extra.ControllerKind != "" {
+		data.Controller = extra.ControllerKind + "/" + extra.ControllerName
+	}
+	var text bytes.Buffer
+	if err := t.Execute(&text, data); err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestRenderMessageEmptyPlaceholder(t *testing.T) {
+	withConfig(t, &Config{EmptyMessage: "(no message)"})
+	for _, message := range []string{"", "  \n"} {
+		event := testEvent("app", "Pod", "web-1", "Unhealthy", message)
+		if got := renderMessage("slack", event, &enrichment{}); got != "(no message)" {
+			t.Errorf("message %q rendered as %q, want the placeholder", message, got)
+		}
+	}
+	event := testEvent("app", "Pod", "web-1", "Unhealthy", "Readiness probe failed")
+	if got := renderMessage("slack", event, &enrichment{}); got != "Readiness probe failed" {
+		t.Errorf("rendered %q, want the message", got)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// threadTracker remembers the parent message posted for each object so
+// its later events are replied in that thread, giving a per-object
+// changelog, while the parent is kept showing the latest status.
+type threadTracker struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	threads   map[string]*slackThread
+	nextSweep time.Time
+}
+
+type slackThread struct {
+	token     string
+	channelID string
+	ts        string
+	lastPost  time.Time
+
+	// The worst the object got so far, to recognize escalations.
+	severity string
+	bucket   int
+
+	// posting is set while the parent is being posted, and closed once it
+	// was, so the object's other events wait to reply to it.
+	posting chan struct{}
+}
+
+var (
+	threads *threadTracker
+
+	threadBroadcasts = newCounterVec("slack_thread_broadcasts_total", "Thread replies also shown in the channel as they escalated.")
+)
+
+func newThreadTracker(ttl time.Duration) *threadTracker {
+	return &threadTracker{ttl: ttl, threads: map[string]*slackThread{}}
+}
+
+// thread returns the live thread of key, forgetting expired ones once a
+// minute. The caller holds t.mu.
+func (t *threadTracker) thread(key string) *slackThread {
+	now := time.Now()
+	if now.After(t.nextSweep) {
+		for k, thread := range t.threads {
+			if now.Sub(thread.lastPost) > t.ttl {
+				delete(t.threads, k)
+			}
+		}
+		t.nextSweep = now.Add(time.Minute)
+	}
+	thread, ok := t.threads[key]
+	if !ok || now.Sub(thread.lastPost) > t.ttl {
+		return nil
+	}
+	return thread
+}
+
+// escalate reports whether the event is worse than the thread's earlier
+// ones: of a higher severity, or past another DEDUP_COUNT_BUCKETS boundary.
+// The caller holds t.mu.
+func (t *threadTracker) escalate(thread *slackThread, event *v1.Event) bool {
+	escalated := false
+	if severity := severityOf(event); severityRank(severity) > severityRank(thread.severity) {
+		thread.severity, escalated = severity, true
+	}
+	if bucket := countBucket(event.Count); bucket > thread.bucket {
+		thread.bucket, escalated = bucket, true
+	}
+	return escalated
+}
+
+// post sends the message as the parent of the event's object, or as a
+// compact reply in the object's thread, updating the parent to the
+// message. token is the destination's, empty for SLACK_BOT_TOKEN.
+func (t *threadTracker) post(token, channel string, message SlackMessage, event *v1.Event) (*slackAPIResponse, error) {
+	// The token tells apart the same channel name in several workspaces.
+	key := token + "/" + channel + "/" + event.ClusterName + "/" + event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name
+	t.mu.Lock()
+	parent := t.thread(key)
+	for parent != nil && parent.posting != nil {
+		posting := parent.posting
+		t.mu.Unlock()
+		<-posting
+		t.mu.Lock()
+		parent = t.thread(key)
+	}
+	if parent == nil {
+		// Reserve the thread so the object's other events wait for the
+		// parent instead of posting their own.
+		parent = &slackThread{token: token, lastPost: time.Now(), severity: severityOf(event), bucket: countBucket(event.Count), posting: make(chan struct{})}
+		t.threads[key] = parent
+		t.mu.Unlock()
+
+		posted, err := postSlackAPI(apiToken(token), channel, message)
+
+		t.mu.Lock()
+		if err != nil {
+			delete(t.threads, key)
+		} else {
+			parent.channelID, parent.ts, parent.lastPost = posted.Channel, posted.TS, time.Now()
+		}
+		close(parent.posting)
+		parent.posting = nil
+		t.mu.Unlock()
+		return posted, err
+	}
+	t.mu.Unlock()
+
+	reply := SlackMessage{
+		ThreadTS: parent.ts,
+		Text:     fmt.Sprintf("*%s* %s", event.Reason, messageText("slack", event, &enrichment{})),
+	}
+	t.mu.Lock()
+	escalated := t.escalate(parent, event)
+	t.mu.Unlock()
+	if escalated && cfg.SlackThreadBroadcast {
+		reply.ReplyBroadcast = true
+		if broadcastTemplate != nil {
+			if text, err := executeTemplate(broadcastTemplate, event, &enrichment{}); err != nil {
+				log.Printf("Unable to render %s: %v", broadcastTemplate.Name(), err)
+			} else {
+				reply.Text = text
+			}
+		}
+	}
+	posted, err := postSlackAPI(apiToken(token), channel, reply)
+	if err != nil {
+		return nil, err
+	}
+	if reply.ReplyBroadcast {
+		threadBroadcasts.inc()
+	}
+	t.mu.Lock()
+	parent.lastPost = time.Now()
+	t.mu.Unlock()
+	if err := updateSlackAPI(apiToken(parent.token), parent.channelID, parent.ts, message); err != nil {
+		log.Printf("Unable to update the thread of %s %s/%s: %v", event.InvolvedObject.Kind, event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+	}
+	return posted, nil
+}
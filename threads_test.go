@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThreadExpires(t *testing.T) {
+	tracker := newThreadTracker(time.Hour)
+	tracker.threads["live"] = &slackThread{ts: "1.0", lastPost: time.Now()}
+	tracker.threads["expired"] = &slackThread{ts: "2.0", lastPost: time.Now().Add(-2 * time.Hour)}
+
+	if tracker.thread("live") == nil {
+		t.Error("a live thread was forgotten")
+	}
+	if tracker.thread("expired") != nil {
+		t.Error("an expired thread was replied to")
+	}
+	if _, ok := tracker.threads["expired"]; ok {
+		t.Error("an expired thread was not swept")
+	}
+
+	// Until the next sweep expired threads are only skipped.
+	tracker.threads["expired"] = &slackThread{ts: "2.0", lastPost: time.Now().Add(-2 * time.Hour)}
+	if tracker.thread("expired") != nil {
+		t.Error("an expired thread was replied to between sweeps")
+	}
+	if _, ok := tracker.threads["expired"]; !ok {
+		t.Error("threads were swept again within a minute")
+	}
+}
+
+func TestConcurrentEventsShareOneThread(t *testing.T) {
+	withConfig(t, &Config{})
+	var mu sync.Mutex
+	parents, replies := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message SlackMessage
+		json.NewDecoder(r.Body).Decode(&message)
+		if strings.HasSuffix(r.URL.Path, "chat.postMessage") {
+			mu.Lock()
+			if message.ThreadTS == "" {
+				parents++
+			} else {
+				replies++
+			}
+			mu.Unlock()
+			// Keep the parent in flight while the other events arrive.
+			time.Sleep(20 * time.Millisecond)
+		}
+		json.NewEncoder(w).Encode(slackAPIResponse{OK: true, Channel: "C1", TS: "1.0"})
+	}))
+	defer server.Close()
+	saved := slackAPI
+	slackAPI = server.URL + "/"
+	t.Cleanup(func() { slackAPI = saved })
+
+	tracker := newThreadTracker(time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			event := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+			if _, err := tracker.post("xoxb-1", "#alerts", SlackMessage{Text: "web-1"}, event); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if parents != 1 || replies != 2 {
+		t.Errorf("posted %d parents and %d replies, want one parent and the other events in its thread", parents, replies)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/util/flowcontrol"
+)
+
+// throttle rate limits notifications per key, the key being THROTTLE_KEY
+// executed over the event, e.g. "{{.Namespace}}" or "{{.Namespace}}/{{.Reason}}".
+// Each key gets its own token bucket of THROTTLE_RATE notifications a
+// minute with bursts of THROTTLE_BURST.
+type throttle struct {
+	key   *template.Template
+	rate  float64
+	burst int
+
+	// owner is whether the key uses the controller, which has to be
+	// resolved for it.
+	owner bool
+
+	mu      sync.Mutex
+	buckets map[string]*throttleBucket
+	labeled map[string]bool // keys notifications_throttled_total has a series of
+}
+
+type throttleBucket struct {
+	limiter  flowcontrol.RateLimiter
+	lastUsed time.Time
+}
+
+// maxThrottleLabels bounds the keys notifications_throttled_total is
+// labeled with, as keys of object names have as many values as there are
+// objects. Throttled notifications of further keys are counted as "other".
+const maxThrottleLabels = 100
+
+var (
+	throttles *throttle
+
+	throttledNotifications = newCounterVec("notifications_throttled_total", "Notifications held back by THROTTLE_RATE, by throttle key.", "key")
+	throttleKeys           = newGaugeFunc("throttle_keys", "Throttle keys currently tracked.", func() float64 {
+		if throttles == nil {
+			return 0
+		}
+		throttles.mu.Lock()
+		defer throttles.mu.Unlock()
+		return float64(len(throttles.buckets))
+	})
+)
+
+func newThrottle(key string, rate float64, burst int) (*throttle, error) {
+	t, err := template.New("THROTTLE_KEY").Option("missingkey=error").Parse(key)
+	if err != nil {
+		return nil, err
+	}
+	return &throttle{
+		key:     t,
+		rate:    rate,
+		burst:   burst,
+		owner:   strings.Contains(key, ".Controller"),
+		buckets: map[string]*throttleBucket{},
+		labeled: map[string]bool{},
+	}, nil
+}
+
+// keyOf executes THROTTLE_KEY over the event. A key that fails to render
+// throttles the event with the others that failed.
+func (t *throttle) keyOf(clientset *kubernetes.Clientset, event *v1.Event) string {
+	data := templateData{
+		Event:     event,
+		Namespace: event.InvolvedObject.Namespace,
+		Kind:      event.InvolvedObject.Kind,
+		Name:      event.InvolvedObject.Name,
+		Reason:    canonicalReason(event.Reason),
+		Message:   eventMessage(event),
+		Count:     event.Count,
+	}
+	if t.owner {
+		if controller, err := cachedController(clientset, event.InvolvedObject); err == nil {
+			data.Controller = controller.Kind + "/" + controller.Name
+		} else {
+			data.Controller = event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name
+		}
+	}
+	var key bytes.Buffer
+	if err := t.key.Execute(&key, data); err != nil {
+		return fmt.Sprintf("(%v)", err)
+	}
+	return key.String()
+}
+
+// admit takes a token from the bucket of the event's key, reporting whether
+// the event may be notified.
+func (t *throttle) admit(clientset *kubernetes.Clientset, event *v1.Event) bool {
+	key := t.keyOf(clientset, event)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bucket, ok := t.buckets[key]
+	if !ok {
+		bucket = &throttleBucket{limiter: flowcontrol.NewTokenBucketRateLimiter(float32(t.rate/60), t.burst)}
+		t.buckets[key] = bucket
+	}
+	bucket.lastUsed = time.Now()
+	if bucket.limiter.TryAccept() {
+		return true
+	}
+	throttledNotifications.inc(t.label(key))
+	return false
+}
+
+// label is the notifications_throttled_total label of key, within
+// maxThrottleLabels. The caller holds t.mu.
+func (t *throttle) label(key string) string {
+	if !t.labeled[key] {
+		if len(t.labeled) >= maxThrottleLabels {
+			return "other"
+		}
+		t.labeled[key] = true
+	}
+	return key
+}
+
+// wouldAdmit reports what admit would without taking a token.
+func (t *throttle) wouldAdmit(clientset *kubernetes.Clientset, event *v1.Event) bool {
+	key := t.keyOf(clientset, event)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bucket, ok := t.buckets[key]
+	return !ok || bucket.limiter.Saturation() < 1
+}
+
+// run forgets the buckets idle for long enough to have refilled, which a
+// new bucket would be too, so keys such as object names don't accumulate.
+func (t *throttle) run() {
+	refill := time.Duration(float64(t.burst) / t.rate * float64(time.Minute))
+	if refill < time.Minute {
+		refill = time.Minute
+	}
+	for range time.Tick(refill) {
+		t.mu.Lock()
+		for key, bucket := range t.buckets {
+			if time.Since(bucket.lastUsed) > refill {
+				bucket.limiter.Stop()
+				delete(t.buckets, key)
+			}
+		}
+		t.mu.Unlock()
+	}
+}
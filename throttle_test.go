@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestThrottleLabelsAreBounded(t *testing.T) {
+	withConfig(t, &Config{})
+	limiter, err := newThrottle("{{.Name}}", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := counterValue(throttledNotifications, "other")
+	for i := 0; i < maxThrottleLabels+10; i++ {
+		event := testEvent("app", "Pod", fmt.Sprintf("web-%d", i), "BackOff", "")
+		if !limiter.admit(nil, event) {
+			t.Fatalf("the first event of %s was throttled", event.InvolvedObject.Name)
+		}
+		if limiter.admit(nil, event) {
+			t.Fatalf("the burst of %s was exceeded", event.InvolvedObject.Name)
+		}
+	}
+	if len(limiter.labeled) != maxThrottleLabels {
+		t.Errorf("%d keys labeled, want %d", len(limiter.labeled), maxThrottleLabels)
+	}
+	if got := counterValue(throttledNotifications, "other") - other; got != 10 {
+		t.Errorf("%v throttled notifications counted as other, want 10", got)
+	}
+}
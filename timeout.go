@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// errNotifyTimeout is returned for sends that took longer than
+// NOTIFY_TIMEOUT.
+var errNotifyTimeout = errors.New("notification timed out")
+
+var notifyTimeouts = newCounterVec("notify_timeouts_total", "Notifications that exceeded NOTIFY_TIMEOUT, by the stage that did.", "stage")
+
+// enrichWithin enriches the event unless that takes longer than
+// NOTIFY_TIMEOUT, in which case the event is notified without enrichment.
+// Like the lookups bounded by ENRICHMENT_TIMEOUT, the enrichment is
+// abandoned rather than cancelled.
+func enrichWithin(clientset *kubernetes.Clientset, event *v1.Event) *enrichment {
+	if cfg.NotifyTimeout <= 0 {
+		return enrichEvent(clientset, event)
+	}
+	done := make(chan *enrichment, 1)
+	go func() {
+		done <- enrichEvent(clientset, event)
+	}()
+	select {
+	case extra := <-done:
+		return extra
+	case <-time.After(cfg.NotifyTimeout):
+		notifyTimeouts.inc("enrichment")
+		log.Printf("Enriching %s on %s/%s exceeded NOTIFY_TIMEOUT, notifying without it", event.Reason, event.InvolvedObject.Namespace, event.InvolvedObject.Name)
+		return &enrichment{Skipped: true}
+	}
+}
+
+// notifySinkWithin is notifySink bounded by NOTIFY_TIMEOUT, returning
+// errNotifyTimeout when the backend did not answer in time.
+func notifySinkWithin(n Notifier, event *v1.Event, extra *enrichment) error {
+	if cfg.NotifyTimeout <= 0 {
+		return notifySink(n, event, extra)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- notifySink(n, event, extra)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(cfg.NotifyTimeout):
+		notifyTimeouts.inc("send")
+		return errNotifyTimeout
+	}
+}
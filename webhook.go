@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// webhookPayload is the backend-neutral JSON document describing an event
+// for generic sinks.
+type webhookPayload struct {
+	Cluster        string    `json:"cluster,omitempty"`
+	Type           string    `json:"type"`
+	Namespace      string    `json:"namespace"`
+	Kind           string    `json:"kind"`
+	Name           string    `json:"name"`
+	Reason         string    `json:"reason"`
+	Message        string    `json:"message"`
+	Count          int32     `json:"count"`
+	FirstTimestamp time.Time `json:"firstTimestamp"`
+	LastTimestamp  time.Time `json:"lastTimestamp"`
+	Controller     string    `json:"controller,omitempty"`
+	Link           string    `json:"link,omitempty"`
+	Note           string    `json:"note,omitempty"`
+	Release        string    `json:"release,omitempty"`
+	Capacity       []string  `json:"capacity,omitempty"`
+	Job            string    `json:"job,omitempty"`
+	CronJob        string    `json:"cronJob,omitempty"`
+}
+
+// webhookNotifier posts events to GENERIC_WEBHOOK_URL, gzip compressing
+// the body when WEBHOOK_GZIP is set and the sink accepts it.
+type webhookNotifier struct {
+	url  string
+	gzip bool
+}
+
+func (webhookNotifier) Name() string { return "webhook" }
+
+func newWebhookPayload(backend string, event *v1.Event, extra *enrichment) webhookPayload {
+	payload := webhookPayload{
+		Cluster:        event.ClusterName,
+		Type:           event.Type,
+		Namespace:      event.InvolvedObject.Namespace,
+		Kind:           event.InvolvedObject.Kind,
+		Name:           event.InvolvedObject.Name,
+		Reason:         event.Reason,
+		Message:        renderMessage(backend, event, extra),
+		Count:          event.Count,
+		FirstTimestamp: event.FirstTimestamp.Time,
+		LastTimestamp:  event.LastTimestamp.Time,
+		Link:           resourceUrl(event),
+		Release:        cfg.ReleaseID,
+		Capacity:       extra.Capacity,
+		Job:            extra.Job,
+		CronJob:        extra.CronJob,
+	}
+	if extra.Skipped {
+		payload.Note = enrichmentSkippedNote
+	}
+	if extra.ControllerKind != "" {
+		payload.Controller = extra.ControllerKind + "/" + extra.ControllerName
+	}
+	return payload
+}
+
+// webhookFields lists the JSON keys of webhookPayload.
+func webhookFields() []string {
+	var fields []string
+	payload := reflect.TypeOf(webhookPayload{})
+	for i := 0; i < payload.NumField(); i++ {
+		fields = append(fields, strings.Split(payload.Field(i).Tag.Get("json"), ",")[0])
+	}
+	return fields
+}
+
+// remapPayload applies WEBHOOK_FIELD_MAP and WEBHOOK_EXCLUDE_FIELDS to the
+// payload, so it can match the schema a sink expects.
+func remapPayload(payload webhookPayload) interface{} {
+	if len(cfg.WebhookFieldMap) == 0 && len(cfg.WebhookExcludeFields) == 0 {
+		return payload
+	}
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return payload
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return payload
+	}
+	remapped := map[string]interface{}{}
+	for field, value := range fields {
+		if containsString(cfg.WebhookExcludeFields, field) {
+			continue
+		}
+		if name, ok := cfg.WebhookFieldMap[field]; ok {
+			field = name
+		}
+		remapped[field] = value
+	}
+	return remapped
+}
+
+func (n webhookNotifier) Notify(event *v1.Event, extra *enrichment) error {
+	body := &bytes.Buffer{}
+	if err := encodeBody(body, remapPayload(newWebhookPayload("webhook", event, extra)), n.gzip); err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", n.url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setHeaders(req)
+	if n.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+var headerName = regexp.MustCompile(`^[A-Za-z0-9!#$%&'*+.^_|~-]+$`)
+
+// parseHeaders parses WEBHOOK_HEADERS, comma separated Name=value pairs.
+// Values are left out of errors as they often hold credentials.
+func parseHeaders(value string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !headerName.MatchString(name) {
+			return nil, fmt.Errorf("expected Name=value, got an entry for %q", name)
+		}
+		headers[http.CanonicalHeaderKey(name)] = strings.TrimSpace(parts[1])
+	}
+	return headers, nil
+}
+
+// setHeaders adds WEBHOOK_HEADERS to a webhook request.
+func setHeaders(req *http.Request) {
+	for name, value := range cfg.WebhookHeaders {
+		req.Header.Set(name, value)
+	}
+}
+
+// encodeBody writes payload as JSON to w, optionally gzip compressed.
+func encodeBody(w io.Writer, payload interface{}, compress bool) error {
+	if !compress {
+		return json.NewEncoder(w).Encode(payload)
+	}
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(payload); err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookGzipRoundTrip(t *testing.T) {
+	withConfig(t, &Config{})
+	event := testEvent("app", "Pod", "web-1", "BackOff", "Back-off restarting failed container")
+
+	for _, compress := range []bool{false, true} {
+		var encoding string
+		var payload webhookPayload
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding = r.Header.Get("Content-Encoding")
+			body := r.Body
+			if encoding == "gzip" {
+				zr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					t.Errorf("invalid gzip body: %v", err)
+					return
+				}
+				defer zr.Close()
+				body = zr
+			}
+			if err := json.NewDecoder(body).Decode(&payload); err != nil {
+				t.Errorf("invalid JSON body: %v", err)
+			}
+		}))
+		err := webhookNotifier{url: server.URL, gzip: compress}.Notify(event, &enrichment{})
+		server.Close()
+		if err != nil {
+			t.Fatalf("gzip %v: %v", compress, err)
+		}
+		if want := map[bool]string{false: "", true: "gzip"}[compress]; encoding != want {
+			t.Errorf("gzip %v: Content-Encoding %q, want %q", compress, encoding, want)
+		}
+		if payload.Name != "web-1" || payload.Reason != "BackOff" || payload.Message != event.Message {
+			t.Errorf("gzip %v: decoded payload %+v", compress, payload)
+		}
+	}
+}